@@ -2,6 +2,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
@@ -9,11 +11,15 @@ import (
 
 	"github.com/aarangop/obsidian-sync/internal/config"
 	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/redact"
 	"github.com/aarangop/obsidian-sync/internal/watcher"
 )
 
 func main() {
-	cfg, err := config.Load()
+	cfg, err := config.LoadWithFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		os.Exit(1)
@@ -28,15 +34,28 @@ func main() {
 		MaxAge:        30,  // 30 days
 		Compress:      true,
 		ConsoleOutput: true,
+		Format:        cfg.LogFormat,
+
+		ComponentLevels: cfg.LogLevels,
 	}
 	logger.Initialize(logConfig)
 
+	// Redact sensitive text before anything, including the configuration, is logged
+	redactor, err := redact.New(cfg.RedactRules())
+	if err != nil {
+		logger.Fatalf("Invalid redaction rules: %v", err)
+	}
+	logger.SetRedactor(redactor)
+
 	logger.Info("🔧 Debug Mode: Obsidian Sync")
 	logger.Infof("📋 Config: %s", cfg.String())
 
+	// Debug the first vault when several are configured
+	vault := cfg.VaultDefinitions()[0]
+
 	// Check if vault path exists and list some files
-	logger.Infof("📁 Checking vault path: %s", cfg.VaultPath)
-	entries, err := os.ReadDir(cfg.VaultPath)
+	logger.InfoWithFields("📁 Checking vault path", logger.Fields{"path": vault.Path, "vault": vault.ID})
+	entries, err := os.ReadDir(vault.Path)
 	if err != nil {
 		logger.Fatalf("Cannot read vault directory: %v", err)
 	}
@@ -47,14 +66,15 @@ func main() {
 			logger.Infof("   ... and %d more", len(entries)-5)
 			break
 		}
-		logger.Infof("   %s (dir: %t)", entry.Name(), entry.IsDir())
+		logger.InfoWithFields("   Entry", logger.Fields{"path": entry.Name(), "dir": entry.IsDir()})
 	}
 
 	// Set up graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	w := watcher.New(cfg.VaultPath)
+	w := watcher.New(vault.Path)
+	w.SetVaultID(vault.ID)
 
 	// Start watcher in a goroutine so we can handle shutdown
 	go func() {
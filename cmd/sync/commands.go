@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/config"
+	"github.com/aarangop/obsidian-sync/internal/encryption"
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/restore"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+)
+
+// commands run instead of the daemon as "obsidian-sync <command> [flags] [args]"
+var commands = map[string]func(args []string) error{
+	"keygen":  runKeygen,
+	"decrypt": runDecrypt,
+	"restore": runRestore,
+	"history": runHistory,
+}
+
+// timeLayouts are the accepted formats of -at, in local time unless the zone is given
+var timeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// keyFileFlag adds the -key-file flag, defaulting to $ENCRYPTION_KEY_FILE
+func keyFileFlag(fs *flag.FlagSet) *string {
+	return fs.String("key-file", os.Getenv("ENCRYPTION_KEY_FILE"), "path to the encryption key file")
+}
+
+// runKeygen creates the key file, or rotates the key by adding a new active key to it
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ContinueOnError)
+	keyFile := keyFileFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyFile == "" {
+		return errors.New("set -key-file or ENCRYPTION_KEY_FILE")
+	}
+
+	id, err := encryption.AddKey(*keyFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔑 Added key %s to %s, new uploads are encrypted with it\n", id, *keyFile)
+	return nil
+}
+
+// runDecrypt decrypts an object downloaded from the bucket
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := keyFileFlag(fs)
+	output := fs.String("o", "", "file to write the decrypted content to, stdout when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: obsidian-sync decrypt [-key-file keys] [-o output] <file>")
+	}
+
+	keys, err := encryption.OpenKeyFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	keyring, err := keys.Keyring()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	plaintext, err := keyring.Open(data)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %v", fs.Arg(0), err)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(plaintext)
+		return err
+	}
+	return os.WriteFile(*output, plaintext, 0644)
+}
+
+// runRestore downloads a vault from its bucket, decrypting it with the configured
+// key file, optionally as it was at an earlier time
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	vaultID := fs.String("vault-id", "", "vault to restore, required when several are configured")
+	to := fs.String("to", "", "folder to restore into, the vault itself when empty")
+	overwrite := fs.Bool("overwrite", false, "replace existing files, even with changes that were not synced")
+	at := fs.String("at", "", "restore the versions current at this time, e.g. \"2025-06-08 14:30\"")
+	settings := fs.Bool("settings", false, "restore the .obsidian settings bundle instead of the notes")
+	cfg, err := config.LoadWithFlagSet(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var when time.Time
+	if *at != "" {
+		if when, err = parseTime(*at); err != nil {
+			return err
+		}
+	}
+
+	vault, store, err := openVault(cfg, *vaultID)
+	if err != nil {
+		return err
+	}
+
+	dir := *to
+	if dir == "" {
+		dir = vault.Path
+	}
+
+	if *settings {
+		count, err := restore.Settings(context.Background(), store, vault.S3Prefix, dir)
+		fmt.Printf("📥 Restored %d settings files into %s\n", count, dir)
+		return err
+	}
+
+	paths, err := vaultPaths(vault, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	opts := restore.Options{Paths: paths, Overwrite: *overwrite, Layout: cfg.S3Layout, At: when}
+	result, err := restore.Restore(context.Background(), store, vault.S3Prefix, dir, opts)
+	fmt.Printf("📥 Restored %d files into %s, skipped %d files with local changes\n", result.Restored, dir, result.Skipped)
+	return err
+}
+
+// runHistory lists the synced versions of a file
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	vaultID := fs.String("vault-id", "", "vault of the file, required when several are configured")
+	cfg, err := config.LoadWithFlagSet(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: obsidian-sync history [-vault-id id] <path>")
+	}
+	if cfg.S3Layout != uploader.LayoutContent {
+		return errors.New("history is only kept with s3.layout: content")
+	}
+
+	vault, store, err := openVault(cfg, *vaultID)
+	if err != nil {
+		return err
+	}
+	paths, err := vaultPaths(vault, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	manifest, err := uploader.LoadManifest(context.Background(), store, vault.S3Prefix)
+	if err != nil {
+		return err
+	}
+	versions := manifest.Versions(paths[0])
+	if len(versions) == 0 {
+		return fmt.Errorf("no synced versions of %s", paths[0])
+	}
+
+	// Newest first
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		when := version.Modified.Local().Format("2006-01-02 15:04:05")
+		switch {
+		case version.Deleted:
+			fmt.Printf("%s  deleted\n", when)
+		case i == len(versions)-1:
+			fmt.Printf("%s  %8d bytes  %s  (current)\n", when, version.Size, version.Hash[:12])
+		default:
+			fmt.Printf("%s  %8d bytes  %s\n", when, version.Size, version.Hash[:12])
+		}
+	}
+	return nil
+}
+
+// openVault returns the selected vault and its object store, decrypting with the configured key file
+func openVault(cfg *config.Config, vaultID string) (config.Vault, uploader.ObjectStore, error) {
+	logger.Initialize(logger.Config{LogLevel: cfg.LogLevel, ConsoleOutput: true, Format: cfg.LogFormat})
+
+	vault, err := selectVault(cfg, vaultID)
+	if err != nil {
+		return vault, nil, err
+	}
+	if vault.S3Bucket == "" {
+		return vault, nil, fmt.Errorf("vault %s has no bucket", vault.ID)
+	}
+
+	s3Store, err := uploader.NewS3Store(context.Background(), vault.S3Bucket, cfg.AWSRegion)
+	if err != nil {
+		return vault, nil, err
+	}
+	s3Store.SetCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey)
+
+	if cfg.EncryptionKeyFile == "" {
+		return vault, s3Store, nil
+	}
+	keys, err := encryption.OpenKeyFile(cfg.EncryptionKeyFile)
+	if err != nil {
+		return vault, nil, err
+	}
+	store := uploader.NewEncryptedStore(s3Store, keys)
+	store.SetAllowPlaintext(cfg.EncryptionAllowPlaintext)
+	return vault, store, nil
+}
+
+// vaultPaths converts paths given on the command line, absolute or relative to
+// the vault, into slash-separated vault paths
+func vaultPaths(vault config.Vault, args []string) ([]string, error) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		if filepath.IsAbs(arg) {
+			rel, err := filepath.Rel(vault.Path, arg)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				return nil, fmt.Errorf("%s is not inside vault %s", arg, vault.Path)
+			}
+			arg = rel
+		}
+		paths = append(paths, filepath.ToSlash(filepath.Clean(arg)))
+	}
+	return paths, nil
+}
+
+// parseTime parses a -at value in one of timeLayouts
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use e.g. \"2025-06-08 14:30\" or RFC 3339", value)
+}
+
+// selectVault returns the vault with the given ID, or the only vault when id is empty
+func selectVault(cfg *config.Config, id string) (config.Vault, error) {
+	vaults := cfg.VaultDefinitions()
+	if id == "" {
+		if len(vaults) > 1 {
+			return config.Vault{}, errors.New("several vaults are configured, select one with -vault-id")
+		}
+		return vaults[0], nil
+	}
+
+	for _, vault := range vaults {
+		if vault.ID == id {
+			return vault, nil
+		}
+	}
+	return config.Vault{}, fmt.Errorf("unknown vault %q", id)
+}
@@ -1,17 +1,40 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/aarangop/obsidian-sync/internal/admin"
 	"github.com/aarangop/obsidian-sync/internal/config"
+	"github.com/aarangop/obsidian-sync/internal/daemon"
+	"github.com/aarangop/obsidian-sync/internal/events"
 	"github.com/aarangop/obsidian-sync/internal/logger"
-	"github.com/aarangop/obsidian-sync/internal/watcher"
+	"github.com/aarangop/obsidian-sync/internal/redact"
+	"github.com/aarangop/obsidian-sync/internal/server"
 )
 
 func main() {
-	cfg, err := config.Load()
+	if len(os.Args) > 1 {
+		if run, exists := commands[os.Args[1]]; exists {
+			if err := run(os.Args[2:]); err != nil && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg, err := config.LoadWithFlags(os.Args[1:])
 
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
 	if err != nil {
 		fmt.Printf("Failed to load config: %v\n", err)
 		os.Exit(1)
@@ -26,16 +49,71 @@ func main() {
 		MaxAge:        30,  // 30 days
 		Compress:      true,
 		ConsoleOutput: true,
+		Format:        cfg.LogFormat,
+
+		ComponentLevels: cfg.LogLevels,
 	}
 	logger.Initialize(logConfig)
 
+	// Redact sensitive text before anything, including the configuration, is logged
+	redactor, err := redact.New(cfg.RedactRules())
+	if err != nil {
+		logger.Fatalf("Invalid redaction rules: %v", err)
+	}
+	logger.SetRedactor(redactor)
+
 	logger.Infof("Obsidian Sync v%s", cfg.Version)
 	logger.Infof("Configuration loaded %s", cfg.String())
 
-	// Create and start watcher
-	w := watcher.New(cfg.VaultPath)
+	// Every vault gets its own watcher and pipeline, events from all of them are streamed
+	hub := events.NewHub()
+	d, err := daemon.New(cfg, hub)
+	if err != nil {
+		logger.Fatalf("Failed to set up vaults: %v", err)
+	}
+
+	srv := server.New(cfg.HTTPPort)
+	adminAPI := admin.New(cfg.AdminToken, d.AdminVaults()...)
+	adminAPI.Register(srv)
+	// Events reveal the paths of the notes, so they need the admin token too
+	srv.Handle("GET /events", adminAPI.Protect(hub))
+	if cfg.AdminToken == "" {
+		logger.Info("🔒 Admin API and event stream disabled, set ADMIN_TOKEN to enable them")
+	}
+	srv.Start()
+
+	// Apply runtime-safe changes when the configuration is reloaded
+	reloader := config.NewReloader(cfg, func(next *config.Config) {
+		logger.SetLevel(next.LogLevel)
+		if err := logger.SetComponentLevels(next.LogLevels); err != nil {
+			logger.Warnf("⚠️ Failed to apply component log levels: %v", err)
+		}
+		if redactor, err := redact.New(next.RedactRules()); err != nil {
+			logger.Warnf("⚠️ Failed to apply redaction rules: %v", err)
+		} else {
+			logger.SetRedactor(redactor)
+		}
+		d.Apply(next)
+		adminAPI.SetToken(next.AdminToken)
+	})
+	if err := reloader.Start(); err != nil {
+		logger.Warnf("⚠️ Configuration hot reload disabled: %v", err)
+	} else {
+		defer reloader.Stop()
+	}
+
+	d.Start()
+
+	// Stop the watchers on shutdown signals so pending events get delivered
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	<-c
+	logger.Info("🛑 Shutting down...")
+	d.Stop()
 
-	if err := w.Start(); err != nil {
-		logger.Fatalf("Failed to start watcher: %v", err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Stop(ctx); err != nil {
+		logger.Warnf("⚠️ Failed to stop HTTP server: %v", err)
 	}
 }
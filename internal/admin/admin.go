@@ -0,0 +1,283 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// log is the api component logger
+var log = logger.Component("api")
+
+// flushTimeout bounds how long a /flush request waits for delivery
+const flushTimeout = 30 * time.Second
+
+// Watcher is the part of watcher.Watcher driven by the admin API.
+type Watcher interface {
+	Resync(path string) (int, error)
+	Flush()
+}
+
+// Pipeline is the part of pipeline.Pipeline driven by the admin API.
+type Pipeline interface {
+	Pause()
+	Resume()
+	Paused() bool
+	Flush(ctx context.Context) error
+}
+
+// ConflictLog reports the recent conflicts of a vault, e.g. pull.Puller.
+type ConflictLog interface {
+	Conflicts() []models.Conflict
+}
+
+// Mux is where the admin routes get registered, e.g. server.Server.
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// Vault is a vault controlled through the admin API.
+type Vault struct {
+	ID       string
+	Watcher  Watcher
+	Pipeline Pipeline
+	// Conflicts is nil unless the vault pulls remote changes
+	Conflicts ConflictLog
+}
+
+// API serves the authenticated admin endpoints that control syncing at runtime.
+// Every endpoint accepts a ?vault=<id> query parameter to act on a single vault;
+// without it the request applies to all vaults.
+type API struct {
+	mu     sync.RWMutex
+	token  string
+	vaults []Vault
+}
+
+func New(token string, vaults ...Vault) *API {
+	return &API{
+		token:  token,
+		vaults: vaults,
+	}
+}
+
+// SetToken replaces the bearer token, e.g. after a configuration reload.
+func (a *API) SetToken(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.token = token
+}
+
+// Register adds the admin routes to the mux.
+func (a *API) Register(mux Mux) {
+	mux.Handle("GET /status", a.authenticate(a.handleStatus))
+	mux.Handle("POST /resync", a.authenticate(a.handleResync))
+	mux.Handle("POST /pause", a.authenticate(a.handlePause))
+	mux.Handle("POST /resume", a.authenticate(a.handleResume))
+	mux.Handle("POST /flush", a.authenticate(a.handleFlush))
+	mux.Handle("GET /log-levels", a.authenticate(a.handleGetLogLevels))
+	mux.Handle("POST /log-levels", a.authenticate(a.handleSetLogLevel))
+}
+
+// Protect puts a handler outside the admin API, such as the event stream, behind the same bearer token.
+func (a *API) Protect(handler http.Handler) http.Handler {
+	return a.authenticate(handler.ServeHTTP)
+}
+
+// authenticate rejects requests without a matching "Authorization: Bearer <token>" header
+func (a *API) authenticate(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		a.mu.RLock()
+		expected := a.token
+		a.mu.RUnlock()
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		// An empty token never matches, so clearing it on reload locks the API
+		if !ok || expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			log.Warnf("🔒 Rejected unauthenticated admin request: %s %s", r.Method, r.URL.Path)
+			writeJSON(rw, http.StatusUnauthorized, map[string]interface{}{"error": "unauthorized"})
+			return
+		}
+		next(rw, r)
+	})
+}
+
+// selectVaults returns the vaults a request applies to, writing an error response if there are none
+func (a *API) selectVaults(rw http.ResponseWriter, r *http.Request) ([]Vault, bool) {
+	id := r.URL.Query().Get("vault")
+	if id == "" {
+		return a.vaults, true
+	}
+
+	for _, v := range a.vaults {
+		if v.ID == id {
+			return []Vault{v}, true
+		}
+	}
+
+	writeJSON(rw, http.StatusNotFound, map[string]interface{}{"error": fmt.Sprintf("unknown vault %q", id)})
+	return nil, false
+}
+
+func (a *API) handleStatus(rw http.ResponseWriter, r *http.Request) {
+	vaults, ok := a.selectVaults(rw, r)
+	if !ok {
+		return
+	}
+
+	status := make(map[string]interface{}, len(vaults))
+	for _, v := range vaults {
+		conflicts := []models.Conflict{}
+		if v.Conflicts != nil {
+			conflicts = v.Conflicts.Conflicts()
+		}
+		status[v.ID] = map[string]interface{}{"paused": v.Pipeline.Paused(), "conflicts": conflicts}
+	}
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"vaults": status})
+}
+
+func (a *API) handleResync(rw http.ResponseWriter, r *http.Request) {
+	vaults, ok := a.selectVaults(rw, r)
+	if !ok {
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	// A path is relative to one vault, so it is ambiguous across several
+	if path != "" && len(vaults) > 1 {
+		writeJSON(rw, http.StatusBadRequest, map[string]interface{}{"error": "path requires the vault parameter when several vaults are configured"})
+		return
+	}
+	log.Infof("🔄 Admin resync requested (vault: %q, path: %q)", r.URL.Query().Get("vault"), path)
+
+	count := 0
+	var errs []error
+	for _, v := range vaults {
+		n, err := v.Watcher.Resync(path)
+		count += n
+		if err != nil {
+			errs = append(errs, vaultError(v, err, len(vaults)))
+		}
+	}
+	if len(errs) > 0 {
+		writeJSON(rw, http.StatusBadRequest, map[string]interface{}{"error": errors.Join(errs...).Error(), "queued": count})
+		return
+	}
+
+	writeJSON(rw, http.StatusAccepted, map[string]interface{}{"queued": count})
+}
+
+func (a *API) handlePause(rw http.ResponseWriter, r *http.Request) {
+	vaults, ok := a.selectVaults(rw, r)
+	if !ok {
+		return
+	}
+
+	for _, v := range vaults {
+		v.Pipeline.Pause()
+	}
+	writeJSON(rw, http.StatusOK, pausedState(vaults))
+}
+
+func (a *API) handleResume(rw http.ResponseWriter, r *http.Request) {
+	vaults, ok := a.selectVaults(rw, r)
+	if !ok {
+		return
+	}
+
+	for _, v := range vaults {
+		v.Pipeline.Resume()
+	}
+	writeJSON(rw, http.StatusOK, pausedState(vaults))
+}
+
+func (a *API) handleFlush(rw http.ResponseWriter, r *http.Request) {
+	vaults, ok := a.selectVaults(rw, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), flushTimeout)
+	defer cancel()
+
+	for _, v := range vaults {
+		v.Watcher.Flush()
+		if err := v.Pipeline.Flush(ctx); err != nil {
+			writeJSON(rw, http.StatusGatewayTimeout, map[string]interface{}{"error": vaultError(v, err, len(vaults)).Error()})
+			return
+		}
+	}
+
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"flushed": true})
+}
+
+func (a *API) handleGetLogLevels(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, logLevels())
+}
+
+// handleSetLogLevel sets the level of ?component=, or the global level without it.
+// An empty level resets the component to the global level.
+func (a *API) handleSetLogLevel(rw http.ResponseWriter, r *http.Request) {
+	component, level := r.URL.Query().Get("component"), r.URL.Query().Get("level")
+
+	var err error
+	if component == "" {
+		if _, err = logrus.ParseLevel(strings.ToLower(level)); err == nil {
+			logger.SetLevel(level)
+		} else {
+			err = fmt.Errorf("invalid log level %q, use debug, info, warn or error", level)
+		}
+	} else {
+		err = logger.SetComponentLevel(component, level)
+	}
+	if err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	log.Infof("🔧 Log level changed (component: %q, level: %q)", component, level)
+	writeJSON(rw, http.StatusOK, logLevels())
+}
+
+// logLevels describes the global level and the per-component overrides
+func logLevels() map[string]interface{} {
+	level, components := logger.Levels()
+	return map[string]interface{}{"level": level, "components": components}
+}
+
+// pausedState reports whether all given vaults are paused, and the state of each
+func pausedState(vaults []Vault) map[string]interface{} {
+	all := len(vaults) > 0
+	each := make(map[string]bool, len(vaults))
+	for _, v := range vaults {
+		each[v.ID] = v.Pipeline.Paused()
+		all = all && each[v.ID]
+	}
+	return map[string]interface{}{"paused": all, "vaults": each}
+}
+
+// vaultError names the vault in an error when the request spans several
+func vaultError(v Vault, err error, count int) error {
+	if count > 1 {
+		return fmt.Errorf("vault %s: %v", v.ID, err)
+	}
+	return err
+}
+
+func writeJSON(rw http.ResponseWriter, status int, body interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(body); err != nil {
+		log.Warnf("⚠️ Failed to write admin response: %v", err)
+	}
+}
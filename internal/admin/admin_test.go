@@ -0,0 +1,241 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+type fakeWatcher struct {
+	resyncPath string
+	flushed    bool
+}
+
+func (w *fakeWatcher) Resync(path string) (int, error) {
+	w.resyncPath = path
+	return 3, nil
+}
+
+func (w *fakeWatcher) Flush() { w.flushed = true }
+
+type fakePipeline struct {
+	paused  bool
+	flushed bool
+}
+
+func (p *fakePipeline) Pause()       { p.paused = true }
+func (p *fakePipeline) Resume()      { p.paused = false }
+func (p *fakePipeline) Paused() bool { return p.paused }
+func (p *fakePipeline) Flush(ctx context.Context) error {
+	p.flushed = true
+	return nil
+}
+
+func newTestMux(w *fakeWatcher, p *fakePipeline) *http.ServeMux {
+	mux := http.NewServeMux()
+	New("secret", Vault{ID: "default", Watcher: w, Pipeline: p}).Register(mux)
+	return mux
+}
+
+func doRequest(mux http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRejectsMissingOrWrongToken(t *testing.T) {
+	p := &fakePipeline{}
+	mux := newTestMux(&fakeWatcher{}, p)
+
+	for _, token := range []string{"", "wrong"} {
+		rec := doRequest(mux, http.MethodPost, "/pause", token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for token %q, got %d", token, rec.Code)
+		}
+	}
+
+	if p.paused {
+		t.Error("Expected pipeline to stay unpaused after rejected requests")
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	p := &fakePipeline{}
+	mux := newTestMux(&fakeWatcher{}, p)
+
+	if rec := doRequest(mux, http.MethodPost, "/pause", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !p.paused {
+		t.Error("Expected pipeline to be paused")
+	}
+
+	if rec := doRequest(mux, http.MethodPost, "/resume", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if p.paused {
+		t.Error("Expected pipeline to be resumed")
+	}
+}
+
+func TestResyncWithPath(t *testing.T) {
+	w := &fakeWatcher{}
+	mux := newTestMux(w, &fakePipeline{})
+
+	rec := doRequest(mux, http.MethodPost, "/resync?path=daily-notes", "secret")
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", rec.Code)
+	}
+	if w.resyncPath != "daily-notes" {
+		t.Errorf("Expected resync of 'daily-notes', got '%s'", w.resyncPath)
+	}
+}
+
+func TestFlushFlushesWatcherAndPipeline(t *testing.T) {
+	w := &fakeWatcher{}
+	p := &fakePipeline{}
+	mux := newTestMux(w, p)
+
+	if rec := doRequest(mux, http.MethodPost, "/flush", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !w.flushed || !p.flushed {
+		t.Errorf("Expected watcher and pipeline to be flushed, got watcher=%t pipeline=%t", w.flushed, p.flushed)
+	}
+}
+
+func TestRejectsGet(t *testing.T) {
+	mux := newTestMux(&fakeWatcher{}, &fakePipeline{})
+
+	if rec := doRequest(mux, http.MethodGet, "/pause", "secret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestEmptyTokenRejectsEverything(t *testing.T) {
+	mux := http.NewServeMux()
+	api := New("secret", Vault{ID: "default", Watcher: &fakeWatcher{}, Pipeline: &fakePipeline{}})
+	api.Register(mux)
+	api.SetToken("")
+
+	if rec := doRequest(mux, http.MethodPost, "/pause", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with the API disabled, got %d", rec.Code)
+	}
+}
+
+func TestProtectRequiresToken(t *testing.T) {
+	api := New("secret")
+	handler := api.Protect(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusTeapot)
+	}))
+
+	if rec := doRequest(handler, http.MethodGet, "/events", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", rec.Code)
+	}
+	if rec := doRequest(handler, http.MethodGet, "/events", "secret"); rec.Code != http.StatusTeapot {
+		t.Errorf("Expected the protected handler to run with the token, got %d", rec.Code)
+	}
+}
+
+func TestVaultSelection(t *testing.T) {
+	work, personal := &fakePipeline{}, &fakePipeline{}
+	workWatcher := &fakeWatcher{}
+	mux := http.NewServeMux()
+	New("secret",
+		Vault{ID: "work", Watcher: workWatcher, Pipeline: work},
+		Vault{ID: "personal", Watcher: &fakeWatcher{}, Pipeline: personal},
+	).Register(mux)
+
+	if rec := doRequest(mux, http.MethodPost, "/pause?vault=work", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if !work.paused || personal.paused {
+		t.Errorf("Expected only work to be paused, got work=%t personal=%t", work.paused, personal.paused)
+	}
+
+	if rec := doRequest(mux, http.MethodPost, "/pause", "secret"); rec.Code != http.StatusOK || !personal.paused {
+		t.Errorf("Expected all vaults to be paused, got status %d personal=%t", rec.Code, personal.paused)
+	}
+
+	if rec := doRequest(mux, http.MethodPost, "/pause?vault=research", "secret"); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown vault, got %d", rec.Code)
+	}
+
+	if rec := doRequest(mux, http.MethodPost, "/resync?path=daily-notes", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a path without vault, got %d", rec.Code)
+	}
+	if rec := doRequest(mux, http.MethodPost, "/resync?vault=work&path=daily-notes", "secret"); rec.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", rec.Code)
+	}
+	if workWatcher.resyncPath != "daily-notes" {
+		t.Errorf("Expected resync of 'daily-notes' in work, got '%s'", workWatcher.resyncPath)
+	}
+}
+
+func TestSetComponentLogLevel(t *testing.T) {
+	mux := newTestMux(&fakeWatcher{}, &fakePipeline{})
+	defer logger.SetComponentLevels(nil)
+
+	rec := doRequest(mux, http.MethodPost, "/log-levels?component=watcher&level=warn", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if _, levels := logger.Levels(); levels["watcher"] != "warning" {
+		t.Errorf("Expected watcher level 'warning', got '%s'", levels["watcher"])
+	}
+
+	rec = doRequest(mux, http.MethodGet, "/log-levels", "secret")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"watcher":"warning"`) {
+		t.Errorf("Expected the watcher override to be listed, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	for _, target := range []string{"/log-levels?component=disk&level=debug", "/log-levels?level=loud"} {
+		if rec := doRequest(mux, http.MethodPost, target, "secret"); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", target, rec.Code)
+		}
+	}
+}
+
+type fakeConflicts []models.Conflict
+
+func (c fakeConflicts) Conflicts() []models.Conflict { return c }
+
+func TestStatusReportsConflicts(t *testing.T) {
+	mux := http.NewServeMux()
+	conflicts := fakeConflicts{{Path: "note.md", Policy: "merge", Resolution: "keep-both", Copy: "note (conflict 2026-10-16).md"}}
+	New("secret",
+		Vault{ID: "work", Watcher: &fakeWatcher{}, Pipeline: &fakePipeline{}, Conflicts: conflicts},
+		Vault{ID: "personal", Watcher: &fakeWatcher{}, Pipeline: &fakePipeline{paused: true}},
+	).Register(mux)
+
+	rec := doRequest(mux, http.MethodGet, "/status", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Vaults map[string]struct {
+			Paused    bool              `json:"paused"`
+			Conflicts []models.Conflict `json:"conflicts"`
+		} `json:"vaults"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %v", err)
+	}
+	if work := body.Vaults["work"]; len(work.Conflicts) != 1 || work.Conflicts[0].Copy != "note (conflict 2026-10-16).md" {
+		t.Errorf("Expected the work conflict, got %+v", work)
+	}
+	if personal := body.Vaults["personal"]; !personal.Paused || personal.Conflicts == nil {
+		t.Errorf("Expected personal to be paused without conflicts, got %+v", personal)
+	}
+}
@@ -0,0 +1,66 @@
+package canvas
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// Extension is the file extension of Obsidian canvas boards
+const Extension = ".canvas"
+
+// document is the JSON Canvas format Obsidian stores boards in
+type document struct {
+	Nodes []struct {
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Text    string `json:"text"`
+		File    string `json:"file"`
+		Subpath string `json:"subpath"`
+		URL     string `json:"url"`
+	} `json:"nodes"`
+	Edges []struct {
+		ID       string `json:"id"`
+		FromNode string `json:"fromNode"`
+		ToNode   string `json:"toNode"`
+		Label    string `json:"label"`
+	} `json:"edges"`
+}
+
+// IsCanvas reports whether path is a canvas board.
+func IsCanvas(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), Extension)
+}
+
+// Parse reads the cards and edges of a canvas board. Groups only arrange other
+// cards and are left out, as are node types added by later Obsidian versions.
+func Parse(data []byte) (*models.Canvas, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid canvas: %v", err)
+	}
+
+	c := &models.Canvas{
+		Texts: []models.CanvasText{},
+		Files: []models.CanvasFile{},
+		Links: []models.CanvasLink{},
+		Edges: []models.CanvasEdge{},
+	}
+	for _, node := range doc.Nodes {
+		switch node.Type {
+		case "text":
+			c.Texts = append(c.Texts, models.CanvasText{ID: node.ID, Text: node.Text})
+		case "file":
+			c.Files = append(c.Files, models.CanvasFile{ID: node.ID, File: node.File, Subpath: node.Subpath})
+		case "link":
+			c.Links = append(c.Links, models.CanvasLink{ID: node.ID, URL: node.URL})
+		}
+	}
+	for _, edge := range doc.Edges {
+		c.Edges = append(c.Edges, models.CanvasEdge{ID: edge.ID, From: edge.FromNode, To: edge.ToNode, Label: edge.Label})
+	}
+	return c, nil
+}
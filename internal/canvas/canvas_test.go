@@ -0,0 +1,49 @@
+package canvas
+
+import "testing"
+
+const board = `{
+	"nodes": [
+		{"id": "a1", "type": "text", "text": "# Goals\nShip the **canvas** support", "x": 0, "y": 0, "width": 250, "height": 60},
+		{"id": "b2", "type": "file", "file": "Projects/plan.md", "subpath": "#Milestones", "x": 300, "y": 0, "width": 400, "height": 400},
+		{"id": "c3", "type": "link", "url": "https://jsoncanvas.org", "x": 0, "y": 300, "width": 400, "height": 400},
+		{"id": "d4", "type": "group", "label": "Q3", "x": -20, "y": -20, "width": 800, "height": 800}
+	],
+	"edges": [
+		{"id": "e1", "fromNode": "a1", "fromSide": "right", "toNode": "b2", "toSide": "left", "label": "tracked in"}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(board))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(c.Texts) != 1 || c.Texts[0].Text != "# Goals\nShip the **canvas** support" {
+		t.Errorf("Expected the text card, got %+v", c.Texts)
+	}
+	if len(c.Files) != 1 || c.Files[0].File != "Projects/plan.md" || c.Files[0].Subpath != "#Milestones" {
+		t.Errorf("Expected the file card, got %+v", c.Files)
+	}
+	if len(c.Links) != 1 || c.Links[0].URL != "https://jsoncanvas.org" {
+		t.Errorf("Expected the link card, got %+v", c.Links)
+	}
+	if len(c.Edges) != 1 || c.Edges[0].From != "a1" || c.Edges[0].To != "b2" || c.Edges[0].Label != "tracked in" {
+		t.Errorf("Expected the edge from a1 to b2, got %+v", c.Edges)
+	}
+}
+
+func TestParseEmptyAndInvalid(t *testing.T) {
+	// A new board is saved as an empty object
+	c, err := Parse([]byte("{}"))
+	if err != nil || c.Texts == nil || len(c.Edges) != 0 {
+		t.Errorf("Expected an empty board, got %+v (%v)", c, err)
+	}
+	if _, err := Parse([]byte(`{"nodes": [`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+	if !IsCanvas("Boards/Roadmap.canvas") || IsCanvas("Boards/Roadmap.md") {
+		t.Error("Expected only .canvas files to be canvas boards")
+	}
+}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"strconv"
+	"strings"
+	"time"
 
+	"github.com/aarangop/obsidian-sync/internal/options"
 	"github.com/joho/godotenv"
 )
 
@@ -14,70 +18,289 @@ type Config struct {
 	VaultPath string
 
 	// AWS config
+	S3Enabled bool
 	S3Bucket  string
 	AWSRegion string
+	// S3Layout is how vaults are stored: options.LayoutPaths or options.LayoutContent
+	S3Layout string
+	// Earlier versions kept per file with the content layout, see options.HistoryPolicy
+	HistoryVersions  int
+	HistoryRetention time.Duration
+	// PullInterval is how often remote changes are pulled into the vaults, 0 when never
+	PullInterval time.Duration
+	// ConflictPolicy resolves files changed locally and remotely, one of the options.Conflict constants
+	ConflictPolicy string
+	// StateDir holds the sync records of the vaults
+	StateDir string
 
 	// Optional: Other settings
 	LogLevel string
 	LogFile  string
-	HTTPPort int
+	// LogFormat is "text" or "json"
+	LogFormat string
+	// LogLevels overrides LogLevel per component, e.g. {"watcher": "warn"}
+	LogLevels map[string]string
+	HTTPPort  int
+
+	// AdminToken authenticates requests to the admin API; the API is disabled when empty
+	AdminToken string
+
+	// ConfigFile is the config file the settings were read from, if any
+	ConfigFile string
+
+	// Watch config
+	Debounce       time.Duration
+	IgnorePatterns []string
+	// WatchMode selects how vaults are watched, one of the options.Watch constants
+	WatchMode string
+	// PollInterval is how often a polled vault is scanned for changes
+	PollInterval time.Duration
+	// RescanInterval is how often watched vaults are swept for missed changes, 0 when never
+	RescanInterval time.Duration
+	// Symlinks is how symlinks in the vaults are synced, one of the options.Symlink constants
+	Symlinks string
+
+	// AWS credentials; the default AWS credential chain is used when empty
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+
+	// Redaction of sensitive text in logs and, with RedactEvents, streamed events
+	RedactMode     string
+	RedactPatterns []string
+	RedactPaths    []string
+	RedactEvents   bool
+	RedactSalt     string
+
+	// Notes matching a frontmatter key=value pair or carrying one of the tags are never synced
+	PrivateFrontmatter []string
+	PrivateTags        []string
+
+	// SyncSettings uploads each vault's .obsidian settings as a bundle, see uploader.SettingsBundle
+	SyncSettings bool
+
+	// DryRun records what would be uploaded and deleted instead of touching the
+	// buckets, exporting it to DryRunOutput when set, see uploader.RecordingStore
+	DryRun       bool
+	DryRunOutput string
+
+	// EncryptionKeyFile holds the keys encrypting uploads; uploads are not encrypted when empty
+	EncryptionKeyFile string
+	// EncryptionAllowPlaintext reads unencrypted objects even with a key file, see uploader.EncryptedStore
+	EncryptionAllowPlaintext bool
+
+	// Vaults lists the vaults to sync when the config file defines several, see VaultDefinitions
+	Vaults []Vault
+
+	// args are the command line flags the config was loaded with, reused on reload
+	args []string
+	// sources records where each setting came from, keyed by config file key
+	sources map[string]string
 }
 
+// Load reads the configuration from its defaults, the config file, .env and
+// the environment, without command line flags.
 func Load() (*Config, error) {
-	_ = godotenv.Load()
+	return LoadWithFlags(nil)
+}
+
+// LoadWithFlags reads the configuration from every source. Each setting is
+// resolved with increasing precedence: default < config file < .env < environment < flags.
+// The config file is given by the -config flag or the CONFIG_FILE variable.
+func LoadWithFlags(args []string) (*Config, error) {
+	return LoadWithFlagSet(flag.NewFlagSet("obsidian-sync", flag.ContinueOnError), args)
+}
+
+// LoadWithFlagSet is LoadWithFlags for commands with flags of their own: the
+// settings' flags are added to fs, which is then parsed.
+func LoadWithFlagSet(fs *flag.FlagSet, args []string) (*Config, error) {
+	flagValues, configFile, err := parseFlags(fs, args)
+	if err != nil {
+		return nil, err
+	}
+
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .env: %v", err)
+	}
+
+	if configFile == "" {
+		configFile = getenv("CONFIG_FILE")
+	}
+	if configFile == "" {
+		configFile = dotenv["CONFIG_FILE"]
+	}
+
+	fileValues := map[string]string{}
+	var fileVaults []map[string]string
+	if configFile != "" {
+		if fileValues, fileVaults, err = readFile(configFile); err != nil {
+			return nil, err
+		}
+	}
 
 	cfg := &Config{
-		Version:   getEnvWithDefault("APP_VERSION", "dev"),
-		VaultPath: getEnvWithDefault("VAULT_PATH", ""),
-		S3Bucket:  getEnvWithDefault("S3_BUCKET", ""),
-		AWSRegion: getEnvWithDefault("AWS_REGION", "us-east-1"),
-		LogLevel:  getEnvWithDefault("LOG_LEVEL", "info"),
-		LogFile:   getEnvWithDefault("LOG_FILE", "logs/obsidian-sync.log"),
+		ConfigFile: configFile,
+		args:       args,
+		sources:    make(map[string]string),
 	}
 
-	if portStr := os.Getenv("HTTP_PORT"); portStr != "" {
-		port, err := strconv.Atoi(portStr)
+	var problems []string
+	unparsed := make(map[string]bool)
+	// ${VAR} references in the config file resolve against the environment, then .env
+	env := func(name string) string {
+		if v := getenv(name); v != "" {
+			return v
+		}
+		return dotenv[name]
+	}
+	sources := []source{
+		mapSource("file", fileValues, true),
+		envSource(".env", func(key string) string { return dotenv[key] }),
+		envSource("env", getenv),
+		mapSource("flag", flagValues, false),
+	}
+
+	for _, f := range fields {
+		value, source, err := resolve(f, sources, env)
 		if err != nil {
-			return nil, fmt.Errorf("invalid HTTP_PORT: %v", err)
+			problems = append(problems, fmt.Sprintf("%s ($%s) from %s: %v", f.key, f.env, source, err))
+			unparsed[f.key] = true
+			continue
 		}
-		cfg.HTTPPort = port
-	} else {
-		cfg.HTTPPort = 8080
+
+		if err := f.set(cfg, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s ($%s) from %s: %v", f.key, f.env, source, err))
+			unparsed[f.key] = true
+		}
+		cfg.sources[f.key] = source
 	}
 
-	// Validate required fields
-	if err := cfg.validate(); err != nil {
-		return nil, fmt.Errorf("config validation failde: %v", err)
+	for i, values := range fileVaults {
+		vault, err := parseVault(values, env)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("vaults[%d]: %v", i, err))
+			continue
+		}
+		cfg.Vaults = append(cfg.Vaults, vault)
+	}
+
+	// Uploading to S3 is enabled by setting a bucket unless configured explicitly
+	if cfg.sources["s3.enabled"] == "default" {
+		cfg.S3Enabled = cfg.S3Bucket != ""
+		for _, v := range cfg.Vaults {
+			cfg.S3Enabled = cfg.S3Enabled || v.S3Bucket != ""
+		}
+	}
+
+	exportDotenv(dotenv)
+
+	// Validate all fields, reporting parse errors and invalid values together
+	problems = append(problems, cfg.validate(unparsed)...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("config validation failed: %w", &ValidationError{Problems: problems})
 	}
 
 	return cfg, nil
 }
 
-func (c *Config) validate() error {
-	// Check required fields
-	if c.VaultPath == "" {
-		return fmt.Errorf("VAULT_PATH is required")
+// parseFlags returns the setting flags that were set explicitly, keyed by flag name, and the -config flag
+func parseFlags(fs *flag.FlagSet, args []string) (map[string]string, string, error) {
+	var configFile string
+	fs.StringVar(&configFile, "config", "", "path to a YAML or TOML config file")
+	settings := make(map[string]bool)
+	for _, f := range fields {
+		if f.flag != "" {
+			fs.String(f.flag, "", fmt.Sprintf("overrides %s and $%s", f.key, f.env))
+			settings[f.flag] = true
+		}
 	}
 
-	if _, err := os.Stat(c.VaultPath); os.IsNotExist(err) {
-		return fmt.Errorf("vault path does not exist: %s", c.VaultPath)
+	// Flags may follow the arguments, as in "restore notes/a.md -at 14:30"; the
+	// flag package stops at the first argument, so parsing resumes after each one
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, "", err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		if len(args) > fs.NArg() && args[len(args)-fs.NArg()-1] == "--" {
+			// Everything after "--" is an argument
+			positional = append(positional, fs.Args()...)
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	// Leave the arguments in fs.Args()
+	if err := fs.Parse(append([]string{"--"}, positional...)); err != nil {
+		return nil, "", err
 	}
 
-	return nil
+	values := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		if settings[fl.Name] {
+			values[fl.Name] = fl.Value.String()
+		}
+	})
+	return values, configFile, nil
 }
 
-func getEnvWithDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// String returns a string representation (useful for logging)
+// It lists every effective setting with the source it came from, redacting secrets
+func (c *Config) String() string {
+	parts := make([]string, 0, len(fields)+1)
+	if c.ConfigFile != "" {
+		parts = append(parts, fmt.Sprintf("config_file=%s", c.ConfigFile))
 	}
-	return defaultValue
+
+	for _, f := range fields {
+		value := f.get(c)
+		if f.secret && value != "" {
+			value = "***"
+		}
+
+		part := fmt.Sprintf("%s=%s", f.key, value)
+		if source := c.Source(f.key); source != "" {
+			part += fmt.Sprintf(" (%s)", source)
+		}
+		parts = append(parts, part)
+	}
+	if len(c.Vaults) > 0 {
+		parts = append(parts, "vaults="+c.vaultsString())
+	}
+
+	return "Config{" + strings.Join(parts, ", ") + "}"
 }
 
-// String returns a string representation (useful for logging)
-// This implements the Stringer interface we discussed earlier
-func (c *Config) String() string {
-	return fmt.Sprintf("Config{Version: %s, VaultPath: %s, S3Bucket: %s, AWSRegion: %s, LogLevel: %s}",
-		c.Version, c.VaultPath, c.S3Bucket, c.AWSRegion, c.LogLevel)
+// RedactRules returns the redaction rules for redact.New.
+func (c *Config) RedactRules() options.RedactRules {
+	return options.RedactRules{
+		Mode:     c.RedactMode,
+		Patterns: c.RedactPatterns,
+		Paths:    c.RedactPaths,
+		Salt:     c.RedactSalt,
+	}
+}
+
+// HistoryPolicy returns the history kept with the content layout.
+func (c *Config) HistoryPolicy() options.HistoryPolicy {
+	return options.HistoryPolicy{Versions: c.HistoryVersions, Retention: c.HistoryRetention}
+}
+
+// PrivacyRules returns the private note rules for privacy.New.
+func (c *Config) PrivacyRules() options.PrivacyRules {
+	return options.PrivacyRules{
+		Frontmatter: c.PrivateFrontmatter,
+		Tags:        c.PrivateTags,
+	}
+}
+
+// Source reports where the setting with the given config file key came from:
+// "default", "file", ".env", "env" or "flag". It is empty for unknown keys.
+func (c *Config) Source(key string) string {
+	return c.sources[key]
 }
 
 // SetupLogging initializes the logger with configuration from this Config
@@ -2,9 +2,13 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -16,7 +20,10 @@ func TestLoadConfig(t *testing.T) {
 	// Clean up temp directory after test
 	defer os.RemoveAll(tempDir)
 
-	// Create a test markdown file to make it look like a real vault
+	// Create the .obsidian folder and a test markdown file to make it look like a real vault
+	if err := os.Mkdir(filepath.Join(tempDir, ".obsidian"), 0755); err != nil {
+		t.Fatalf("Failed to create .obsidian dir: %v", err)
+	}
 	testFile := filepath.Join(tempDir, "test.md")
 	err = os.WriteFile(testFile, []byte("# Test Note"), 0644)
 	if err != nil {
@@ -53,3 +60,323 @@ func TestLoadConfig(t *testing.T) {
 		t.Errorf("Expected vault path '%s', got '%s'", tempDir, cfg.VaultPath)
 	}
 }
+
+// newVault creates an empty Obsidian vault in a temporary directory
+func newVault(t *testing.T) string {
+	t.Helper()
+	vault := t.TempDir()
+	if err := os.Mkdir(filepath.Join(vault, ".obsidian"), 0755); err != nil {
+		t.Fatalf("Failed to create .obsidian dir: %v", err)
+	}
+	return vault
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	vault := newVault(t)
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+vault+`
+s3:
+  bucket: file-bucket
+  region: eu-west-1
+log:
+  level: warn
+http:
+  port: 9000
+`)
+
+	t.Setenv("CONFIG_FILE", configFile)
+	t.Setenv("S3_BUCKET", "env-bucket")
+	t.Setenv("LOG_LEVEL", "debug")
+
+	cfg, err := LoadWithFlags([]string{"-log-level", "error"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		got    string
+		want   string
+		source string
+	}{
+		{"vault_path", cfg.VaultPath, vault, "file"},
+		{"s3.region", cfg.AWSRegion, "eu-west-1", "file"},
+		{"s3.bucket", cfg.S3Bucket, "env-bucket", "env"},
+		{"log.level", cfg.LogLevel, "error", "flag"},
+		{"log.file", cfg.LogFile, "logs/obsidian-sync.log", "default"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Expected %s '%s', got '%s'", tt.key, tt.want, tt.got)
+		}
+		if source := cfg.Source(tt.key); source != tt.source {
+			t.Errorf("Expected %s from '%s', got '%s'", tt.key, tt.source, source)
+		}
+	}
+
+	if cfg.HTTPPort != 9000 {
+		t.Errorf("Expected HTTP port 9000, got %d", cfg.HTTPPort)
+	}
+}
+
+func TestLoadWithFlagSetAcceptsFlagsAfterArguments(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	at := fs.String("at", "", "")
+
+	cfg, err := LoadWithFlagSet(fs, []string{"notes/a.md", "-at", "14:30", "notes/b.md", "-log-level", "error", "--", "-dash.md"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if *at != "14:30" {
+		t.Errorf("Expected -at '14:30', got '%s'", *at)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("Expected log level 'error', got '%s'", cfg.LogLevel)
+	}
+	if args := strings.Join(fs.Args(), " "); args != "notes/a.md notes/b.md -dash.md" {
+		t.Errorf("Expected arguments 'notes/a.md notes/b.md -dash.md', got '%s'", args)
+	}
+}
+
+func TestLoadTOMLFile(t *testing.T) {
+	vault := newVault(t)
+	configFile := writeConfigFile(t, "config.toml", `
+vault_path = "`+vault+`"
+
+[s3]
+bucket = "toml-bucket"
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.S3Bucket != "toml-bucket" {
+		t.Errorf("Expected S3 bucket 'toml-bucket', got '%s'", cfg.S3Bucket)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: /tmp
+s3:
+  buckt: typo
+`)
+
+	_, err := LoadWithFlags([]string{"-config", configFile})
+	if err == nil || !strings.Contains(err.Error(), "s3.buckt") {
+		t.Errorf("Expected unknown key error mentioning s3.buckt, got %v", err)
+	}
+}
+
+func TestStringShowsSourcesAndRedactsSecrets(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	t.Setenv("ADMIN_TOKEN", "super-secret-admin-token")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	s := cfg.String()
+	if strings.Contains(s, "super-secret-admin-token") {
+		t.Errorf("Expected admin token to be redacted, got %s", s)
+	}
+	if !strings.Contains(s, "log.level=info (default)") {
+		t.Errorf("Expected log level with its source, got %s", s)
+	}
+}
+
+func TestLoadReportsAllProblemsTogether(t *testing.T) {
+	notADir := filepath.Join(t.TempDir(), "vault.md")
+	if err := os.WriteFile(notADir, []byte("# Not a vault"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("VAULT_PATH", notADir)
+	t.Setenv("S3_ENABLED", "true")
+	t.Setenv("AWS_REGION", "bogus")
+	t.Setenv("HTTP_PORT", "70000")
+	t.Setenv("DEBOUNCE_INTERVAL", "soon")
+
+	_, err := Load()
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		"vault path is not a directory",
+		"s3.bucket ($S3_BUCKET): required when s3.enabled is true",
+		`invalid AWS region "bogus"`,
+		"port 70000 is out of range",
+		"watch.debounce ($DEBOUNCE_INTERVAL) from env: not a duration",
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+	if len(validationErr.Problems) != len(expected) {
+		t.Errorf("Expected %d problems, got %d: %v", len(expected), len(validationErr.Problems), validationErr.Problems)
+	}
+}
+
+func TestLoadRejectsVaultWithoutObsidianFolder(t *testing.T) {
+	t.Setenv("VAULT_PATH", t.TempDir())
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "does not look like an Obsidian vault") {
+		t.Errorf("Expected Obsidian vault error, got %v", err)
+	}
+}
+
+func TestS3EnabledFollowsBucketByDefault(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	t.Setenv("S3_BUCKET", "notes-bucket")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !cfg.S3Enabled {
+		t.Error("Expected S3 to be enabled when a bucket is set")
+	}
+}
+
+func TestPullNeedsContentLayout(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	t.Setenv("PULL_INTERVAL", "1m")
+
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "needs s3.layout: content") {
+		t.Errorf("Expected a layout error, got %v", err)
+	}
+
+	t.Setenv("S3_LAYOUT", "content")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.StateDir != "state" {
+		t.Errorf("Expected state dir 'state', got '%s'", cfg.StateDir)
+	}
+	if cfg.ConflictPolicy != "keep-both" {
+		t.Errorf("Expected conflict policy 'keep-both', got '%s'", cfg.ConflictPolicy)
+	}
+
+	t.Setenv("CONFLICT_POLICY", "newest-wins")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "invalid conflict policy") {
+		t.Errorf("Expected a conflict policy error, got %v", err)
+	}
+}
+
+func TestLoadComponentLogLevels(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+newVault(t)+`
+log:
+  levels:
+    watcher: warn
+    uploader: debug
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.LogLevels["watcher"] != "warn" || cfg.LogLevels["uploader"] != "debug" {
+		t.Errorf("Expected watcher=warn and uploader=debug, got %v", cfg.LogLevels)
+	}
+
+	t.Setenv("LOG_LEVELS", "disk=debug")
+	if _, err := LoadWithFlags([]string{"-config", configFile}); err == nil || !strings.Contains(err.Error(), `unknown component "disk"`) {
+		t.Errorf("Expected unknown component error, got %v", err)
+	}
+}
+
+func TestListItemsMayContainCommas(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+newVault(t)+`
+redact:
+  patterns: ['\d{3,4}', 'acme']
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(cfg.RedactPatterns) != 2 || cfg.RedactPatterns[0] != `\d{3,4}` {
+		t.Errorf("Expected patterns [\\d{3,4} acme], got %v", cfg.RedactPatterns)
+	}
+}
+
+func TestHashRedactionNeedsSalt(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	t.Setenv("REDACT_MODE", "hash")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "needs redact.salt") {
+		t.Errorf("Expected a missing salt error, got %v", err)
+	}
+
+	t.Setenv("REDACT_SALT", "pepper")
+	if _, err := Load(); err != nil {
+		t.Errorf("Expected no error with a salt, got %v", err)
+	}
+}
+
+func TestLoadWatchMode(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.WatchMode != "auto" || cfg.PollInterval != 2*time.Second {
+		t.Errorf("Expected watch mode auto polling every 2s, got %s every %s", cfg.WatchMode, cfg.PollInterval)
+	}
+	if cfg.RescanInterval != time.Hour {
+		t.Errorf("Expected rescan interval 1h, got %s", cfg.RescanInterval)
+	}
+	if cfg.Symlinks != "follow" {
+		t.Errorf("Expected symlinks to be followed, got %s", cfg.Symlinks)
+	}
+
+	t.Setenv("WATCH_MODE", "inotify")
+	t.Setenv("WATCH_POLL_INTERVAL", "10ms")
+	t.Setenv("WATCH_RESCAN_INTERVAL", "5s")
+	t.Setenv("WATCH_SYMLINKS", "copy")
+	_, err = Load()
+	for _, want := range []string{`invalid watch mode "inotify"`, "poll interval 10ms must be between 100ms and 1h", "rescan interval 5s must be at least 1m", `invalid symlink policy "copy"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadDryRun(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+
+	cfg, err := LoadWithFlags([]string{"-dry-run=true", "-dry-run-output", "plan.jsonl"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !cfg.DryRun || cfg.DryRunOutput != "plan.jsonl" {
+		t.Errorf("Expected a dry run exported to plan.jsonl, got %v to %q", cfg.DryRun, cfg.DryRunOutput)
+	}
+
+	t.Setenv("DRY_RUN", "maybe")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "not a boolean: maybe") {
+		t.Errorf("Expected an invalid boolean error, got %v", err)
+	}
+}
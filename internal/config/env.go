@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	// exported remembers the values copied from .env into the process environment,
+	// so later loads can tell them apart from variables set by the real environment
+	exported   = make(map[string]string)
+	exportedMu sync.Mutex
+)
+
+// getenv returns a variable from the real environment, ignoring values exported from .env
+func getenv(key string) string {
+	value := os.Getenv(key)
+
+	exportedMu.Lock()
+	defer exportedMu.Unlock()
+	if e, ok := exported[key]; ok && e == value {
+		return ""
+	}
+	return value
+}
+
+// exportDotenv copies .env values into the process environment so libraries such
+// as the AWS SDK still see them. Variables set by the real environment win.
+func exportDotenv(dotenv map[string]string) {
+	exportedMu.Lock()
+	defer exportedMu.Unlock()
+
+	for key, value := range dotenv {
+		if current, set := os.LookupEnv(key); set {
+			if previous, ok := exported[key]; !ok || previous != current {
+				continue
+			}
+		}
+		os.Setenv(key, value)
+		exported[key] = value
+	}
+}
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/options"
+)
+
+// field describes one configuration setting and where it can be set from.
+// Settings resolve with increasing precedence: default < file < .env < env < flag.
+type field struct {
+	key    string // dotted key in the config file, e.g. "s3.bucket"
+	env    string // environment variable, also read from .env
+	flag   string // command line flag; empty when the setting has no flag
+	def    string // default value
+	secret bool   // redacted when printing the configuration
+	// reloadable settings can be changed while running; others need a restart
+	reloadable bool
+
+	get func(c *Config) string
+	set func(c *Config, value string) error
+	// validate checks the parsed value; nil when any value is acceptable
+	validate func(c *Config) error
+}
+
+var fields = []field{
+	{
+		key: "version", env: "APP_VERSION", flag: "app-version", def: "dev",
+		get: func(c *Config) string { return c.Version },
+		set: func(c *Config, v string) error { c.Version = v; return nil },
+	},
+	{
+		key: "vault_path", env: "VAULT_PATH", flag: "vault",
+		get:      func(c *Config) string { return c.VaultPath },
+		set:      func(c *Config, v string) error { c.VaultPath = v; return nil },
+		validate: validateVaultPath,
+	},
+	{
+		key: "s3.bucket", env: "S3_BUCKET", flag: "s3-bucket",
+		get:      func(c *Config) string { return c.S3Bucket },
+		set:      func(c *Config, v string) error { c.S3Bucket = v; return nil },
+		validate: validateBucket,
+	},
+	{
+		// Defaults to whether a bucket is set, see LoadWithFlags
+		key: "s3.enabled", env: "S3_ENABLED", flag: "s3-enabled", def: "false",
+		get: func(c *Config) string { return strconv.FormatBool(c.S3Enabled) },
+		set: func(c *Config, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("not a boolean: %s", v)
+			}
+			c.S3Enabled = enabled
+			return nil
+		},
+	},
+	{
+		key: "s3.region", env: "AWS_REGION", flag: "aws-region", def: "us-east-1",
+		get:      func(c *Config) string { return c.AWSRegion },
+		set:      func(c *Config, v string) error { c.AWSRegion = v; return nil },
+		validate: validateRegion,
+	},
+	{
+		key: "s3.layout", env: "S3_LAYOUT", flag: "s3-layout", def: options.LayoutPaths,
+		get:      func(c *Config) string { return c.S3Layout },
+		set:      func(c *Config, v string) error { c.S3Layout = strings.ToLower(v); return nil },
+		validate: validateLayout,
+	},
+	{
+		key: "history.versions", env: "HISTORY_VERSIONS", flag: "history-versions", def: "10", reloadable: true,
+		get: func(c *Config) string { return strconv.Itoa(c.HistoryVersions) },
+		set: func(c *Config, v string) error {
+			versions, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("not a number: %s", v)
+			}
+			c.HistoryVersions = versions
+			return nil
+		},
+		validate: validateHistoryVersions,
+	},
+	{
+		key: "history.retention", env: "HISTORY_RETENTION", flag: "history-retention", def: "0s", reloadable: true,
+		get: func(c *Config) string { return c.HistoryRetention.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("not a duration: %s", v)
+			}
+			c.HistoryRetention = d
+			return nil
+		},
+		validate: validateHistoryRetention,
+	},
+	{
+		// 0 disables pulling; pulling needs the content layout
+		key: "pull.interval", env: "PULL_INTERVAL", flag: "pull-interval", def: "0s",
+		get: func(c *Config) string { return c.PullInterval.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("not a duration: %s", v)
+			}
+			c.PullInterval = d
+			return nil
+		},
+		validate: validatePullInterval,
+	},
+	{
+		key: "pull.conflict_policy", env: "CONFLICT_POLICY", flag: "conflict-policy", def: options.ConflictKeepBoth, reloadable: true,
+		get:      func(c *Config) string { return c.ConflictPolicy },
+		set:      func(c *Config, v string) error { c.ConflictPolicy = strings.ToLower(v); return nil },
+		validate: validateConflictPolicy,
+	},
+	{
+		key: "state.dir", env: "STATE_DIR", flag: "state-dir", def: "state",
+		get: func(c *Config) string { return c.StateDir },
+		set: func(c *Config, v string) error { c.StateDir = v; return nil },
+	},
+	{
+		key: "log.level", env: "LOG_LEVEL", flag: "log-level", def: "info", reloadable: true,
+		get:      func(c *Config) string { return c.LogLevel },
+		set:      func(c *Config, v string) error { c.LogLevel = v; return nil },
+		validate: validateLogLevel,
+	},
+	{
+		// Per-component overrides, "watcher=warn,uploader=debug" or a log.levels section in the config file
+		key: "log.levels", env: "LOG_LEVELS", flag: "log-levels", reloadable: true,
+		get: func(c *Config) string { return logger.FormatComponentLevels(c.LogLevels) },
+		set: func(c *Config, v string) error {
+			levels, err := logger.ParseComponentLevels(v)
+			if err != nil {
+				return err
+			}
+			c.LogLevels = levels
+			return nil
+		},
+	},
+	{
+		key: "log.file", env: "LOG_FILE", flag: "log-file", def: "logs/obsidian-sync.log",
+		get:      func(c *Config) string { return c.LogFile },
+		set:      func(c *Config, v string) error { c.LogFile = v; return nil },
+		validate: validateLogFile,
+	},
+	{
+		key: "log.format", env: "LOG_FORMAT", flag: "log-format", def: "text",
+		get:      func(c *Config) string { return c.LogFormat },
+		set:      func(c *Config, v string) error { c.LogFormat = strings.ToLower(v); return nil },
+		validate: validateLogFormat,
+	},
+	{
+		key: "http.port", env: "HTTP_PORT", flag: "http-port", def: "8080",
+		get: func(c *Config) string { return strconv.Itoa(c.HTTPPort) },
+		set: func(c *Config, v string) error {
+			port, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("not a number: %s", v)
+			}
+			c.HTTPPort = port
+			return nil
+		},
+		validate: validatePort,
+	},
+	{
+		key: "watch.debounce", env: "DEBOUNCE_INTERVAL", flag: "debounce", def: "100ms", reloadable: true,
+		get: func(c *Config) string { return c.Debounce.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("not a duration: %s", v)
+			}
+			c.Debounce = d
+			return nil
+		},
+		validate: validateDebounce,
+	},
+	{
+		key: "watch.ignore", env: "IGNORE_PATTERNS", flag: "ignore", reloadable: true,
+		get:      func(c *Config) string { return strings.Join(c.IgnorePatterns, ",") },
+		set:      func(c *Config, v string) error { c.IgnorePatterns = splitList(v); return nil },
+		validate: validateIgnorePatterns,
+	},
+	{
+		key: "watch.mode", env: "WATCH_MODE", flag: "watch-mode", def: options.WatchAuto,
+		get:      func(c *Config) string { return c.WatchMode },
+		set:      func(c *Config, v string) error { c.WatchMode = strings.ToLower(v); return nil },
+		validate: validateWatchMode,
+	},
+	{
+		// Used with watch.mode poll, and with auto when the directories can't be watched
+		key: "watch.poll_interval", env: "WATCH_POLL_INTERVAL", flag: "poll-interval", def: "2s",
+		get: func(c *Config) string { return c.PollInterval.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("not a duration: %s", v)
+			}
+			c.PollInterval = d
+			return nil
+		},
+		validate: validatePollInterval,
+	},
+	{
+		// 0 disables the sweep; watcher errors still trigger rescans
+		key: "watch.rescan_interval", env: "WATCH_RESCAN_INTERVAL", flag: "rescan-interval", def: "1h",
+		get: func(c *Config) string { return c.RescanInterval.String() },
+		set: func(c *Config, v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("not a duration: %s", v)
+			}
+			c.RescanInterval = d
+			return nil
+		},
+		validate: validateRescanInterval,
+	},
+	{
+		key: "watch.symlinks", env: "WATCH_SYMLINKS", flag: "symlinks", def: options.SymlinkFollow,
+		get:      func(c *Config) string { return c.Symlinks },
+		set:      func(c *Config, v string) error { c.Symlinks = strings.ToLower(v); return nil },
+		validate: validateSymlinks,
+	},
+	{
+		key: "s3.access_key_id", env: "AWS_ACCESS_KEY_ID", secret: true, reloadable: true,
+		get: func(c *Config) string { return c.AWSAccessKeyID },
+		set: func(c *Config, v string) error { c.AWSAccessKeyID = v; return nil },
+	},
+	{
+		key: "s3.secret_access_key", env: "AWS_SECRET_ACCESS_KEY", secret: true, reloadable: true,
+		get: func(c *Config) string { return c.AWSSecretAccessKey },
+		set: func(c *Config, v string) error { c.AWSSecretAccessKey = v; return nil },
+	},
+	{
+		key: "http.admin_token", env: "ADMIN_TOKEN", secret: true, reloadable: true,
+		get:      func(c *Config) string { return c.AdminToken },
+		set:      func(c *Config, v string) error { c.AdminToken = v; return nil },
+		validate: validateAdminToken,
+	},
+	{
+		key: "redact.mode", env: "REDACT_MODE", flag: "redact-mode", def: options.RedactMask, reloadable: true,
+		get:      func(c *Config) string { return c.RedactMode },
+		set:      func(c *Config, v string) error { c.RedactMode = strings.ToLower(v); return nil },
+		validate: validateRedactMode,
+	},
+	{
+		key: "redact.patterns", env: "REDACT_PATTERNS", flag: "redact-patterns", reloadable: true,
+		get:      func(c *Config) string { return joinList(c.RedactPatterns) },
+		set:      func(c *Config, v string) error { c.RedactPatterns = splitList(v); return nil },
+		validate: validateRedactPatterns,
+	},
+	{
+		key: "redact.paths", env: "REDACT_PATHS", flag: "redact-paths", reloadable: true,
+		get:      func(c *Config) string { return joinList(c.RedactPaths) },
+		set:      func(c *Config, v string) error { c.RedactPaths = splitList(v); return nil },
+		validate: validateRedactPaths,
+	},
+	{
+		key: "redact.events", env: "REDACT_EVENTS", flag: "redact-events", def: "false", reloadable: true,
+		get: func(c *Config) string { return strconv.FormatBool(c.RedactEvents) },
+		set: func(c *Config, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("not a boolean: %s", v)
+			}
+			c.RedactEvents = enabled
+			return nil
+		},
+	},
+	{
+		key: "redact.salt", env: "REDACT_SALT", secret: true, reloadable: true,
+		get: func(c *Config) string { return c.RedactSalt },
+		set: func(c *Config, v string) error { c.RedactSalt = v; return nil },
+	},
+	{
+		// Uploads the .obsidian folder as a bundle next to the vault
+		key: "obsidian.sync_settings", env: "OBSIDIAN_SYNC_SETTINGS", flag: "sync-settings", def: "false",
+		get: func(c *Config) string { return strconv.FormatBool(c.SyncSettings) },
+		set: func(c *Config, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("not a boolean: %s", v)
+			}
+			c.SyncSettings = enabled
+			return nil
+		},
+	},
+	{
+		key: "dry_run.enabled", env: "DRY_RUN", flag: "dry-run", def: "false",
+		get: func(c *Config) string { return strconv.FormatBool(c.DryRun) },
+		set: func(c *Config, v string) error {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("not a boolean: %s", v)
+			}
+			c.DryRun = enabled
+			return nil
+		},
+	},
+	{
+		key: "dry_run.output", env: "DRY_RUN_OUTPUT", flag: "dry-run-output",
+		get: func(c *Config) string { return c.DryRunOutput },
+		set: func(c *Config, v string) error { c.DryRunOutput = v; return nil },
+	},
+	{
+		key: "encryption.key_file", env: "ENCRYPTION_KEY_FILE", flag: "encryption-key-file",
+		get: func(c *Config) string { return c.EncryptionKeyFile },
+		set: func(c *Config, v string) error { c.EncryptionKeyFile = v; return nil },
+	},
+	{
+		key: "encryption.allow_plaintext", env: "ENCRYPTION_ALLOW_PLAINTEXT", flag: "encryption-allow-plaintext", def: "false",
+		get: func(c *Config) string { return strconv.FormatBool(c.EncryptionAllowPlaintext) },
+		set: func(c *Config, v string) error {
+			allow, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("not a boolean: %s", v)
+			}
+			c.EncryptionAllowPlaintext = allow
+			return nil
+		},
+	},
+	{
+		key: "privacy.frontmatter", env: "PRIVACY_FRONTMATTER", flag: "privacy-frontmatter", def: "sync=false,private=true", reloadable: true,
+		get:      func(c *Config) string { return joinList(c.PrivateFrontmatter) },
+		set:      func(c *Config, v string) error { c.PrivateFrontmatter = splitList(v); return nil },
+		validate: validatePrivateFrontmatter,
+	},
+	{
+		key: "privacy.tags", env: "PRIVACY_TAGS", flag: "privacy-tags", reloadable: true,
+		get: func(c *Config) string { return joinList(c.PrivateTags) },
+		set: func(c *Config, v string) error { c.PrivateTags = splitList(v); return nil },
+	},
+}
+
+// joinList is the inverse of splitList
+func joinList(items []string) string {
+	for _, item := range items {
+		if strings.Contains(item, ",") {
+			return strings.Join(items, "\n")
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// splitList parses a comma-separated list, dropping empty entries. Lists whose
+// items contain commas, such as regular expressions, are separated by newlines instead.
+func splitList(value string) []string {
+	separator := ","
+	if strings.Contains(value, "\n") {
+		separator = "\n"
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// lookupField returns the field for a config file key
+func lookupField(key string) (field, bool) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return field{}, false
+}
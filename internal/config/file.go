@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile parses a YAML or TOML config file into flat dotted keys, and the
+// entries of the vaults list. Unknown keys are reported as an error so typos don't go unnoticed.
+func readFile(path string) (map[string]string, []map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, nil, fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	vaults, err := splitVaults(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", raw, values); err != nil {
+		return nil, nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	foldSection(values, "log.levels")
+
+	var unknown []string
+	for key := range values {
+		// <key>_file names a file holding the value of <key>
+		if _, ok := lookupField(strings.TrimSuffix(key, "_file")); !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, nil, fmt.Errorf("unknown keys in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+
+	return values, vaults, nil
+}
+
+// foldSection turns the keys of a section holding a map setting, such as
+// log.levels.watcher=warn, back into the single value log.levels=watcher=warn
+func foldSection(values map[string]string, key string) {
+	var items []string
+	for k, v := range values {
+		if name, ok := strings.CutPrefix(k, key+"."); ok {
+			items = append(items, name+"="+v)
+			delete(values, k)
+		}
+	}
+	if len(items) > 0 {
+		sort.Strings(items)
+		values[key] = strings.Join(items, ",")
+	}
+}
+
+// flatten turns nested sections into dotted keys, e.g. {s3: {bucket: x}} becomes s3.bucket=x
+func flatten(prefix string, raw map[string]interface{}, values map[string]string) error {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, v, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			// Lists are kept in the same form used by environment variables, see splitList
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, nested := item.(map[string]interface{}); nested {
+					return fmt.Errorf("%s: lists of sections are not supported", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = joinList(items)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets editors finish writing before the config is re-read
+const reloadDelay = 500 * time.Millisecond
+
+// Reloader re-reads the configuration when the config file or .env changes, or
+// when the process receives SIGHUP. Changes to reloadable settings are handed to
+// the apply callback; changes to other settings are rejected until restart.
+type Reloader struct {
+	mu         sync.Mutex
+	current    *Config
+	configFile string
+	apply      func(cfg *Config)
+
+	fsWatcher *fsnotify.Watcher
+	signals   chan os.Signal
+	done      chan struct{}
+	timer     *time.Timer
+}
+
+func NewReloader(cfg *Config, apply func(cfg *Config)) *Reloader {
+	return &Reloader{
+		current:    cfg,
+		configFile: cfg.ConfigFile,
+		apply:      apply,
+		signals:    make(chan os.Signal, 1),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start watches the config sources and listens for SIGHUP in the background.
+func (r *Reloader) Start() error {
+	var err error
+	r.fsWatcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	// Watch the directories rather than the files, since editors replace files on save
+	for _, dir := range r.watchedDirs() {
+		if err := r.fsWatcher.Add(dir); err != nil {
+			logger.Warnf("⚠️ Failed to watch %s for config changes: %v", dir, err)
+		}
+	}
+
+	signal.Notify(r.signals, syscall.SIGHUP)
+	go r.run()
+	return nil
+}
+
+func (r *Reloader) Stop() {
+	signal.Stop(r.signals)
+	close(r.done)
+	if r.fsWatcher != nil {
+		r.fsWatcher.Close()
+	}
+}
+
+// Current returns the configuration that is in effect.
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+func (r *Reloader) run() {
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-r.signals:
+			logger.Info("🔄 SIGHUP received, reloading configuration")
+			r.Reload()
+		case event, ok := <-r.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if r.isConfigSource(event.Name) {
+				r.scheduleReload()
+			}
+		case err, ok := <-r.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warnf("⚠️ Config watcher error: %v", err)
+		}
+	}
+}
+
+func (r *Reloader) scheduleReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(reloadDelay, func() {
+		logger.Info("🔄 Configuration changed on disk, reloading")
+		r.Reload()
+	})
+}
+
+// Reload reads the configuration again and applies the reloadable changes.
+// An invalid configuration is rejected as a whole and the current one is kept.
+func (r *Reloader) Reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := LoadWithFlags(r.current.args)
+	if err != nil {
+		logger.Errorf("⚠️ Rejected configuration reload, keeping current configuration: %v", err)
+		return
+	}
+
+	// Start from the current config and only take over reloadable settings
+	merged := *r.current
+	merged.sources = make(map[string]string, len(r.current.sources))
+	for key, source := range r.current.sources {
+		merged.sources[key] = source
+	}
+
+	var applied []string
+	for _, f := range fields {
+		if f.get(r.current) == f.get(next) {
+			continue
+		}
+
+		if !f.reloadable {
+			logger.Warnf("⚠️ Ignoring change to %s: it only takes effect after a restart", f.key)
+			continue
+		}
+
+		f.set(&merged, f.get(next))
+		merged.sources[f.key] = next.sources[f.key]
+		applied = append(applied, f.key)
+	}
+
+	// Adding, removing or moving vaults needs a restart, their ignore patterns don't
+	switch definitions, ignore := compareVaults(r.current.Vaults, next.Vaults); {
+	case definitions:
+		logger.Warn("⚠️ Ignoring change to vaults: it only takes effect after a restart")
+	case ignore:
+		merged.Vaults = next.Vaults
+		applied = append(applied, "vaults.ignore")
+	}
+
+	if len(applied) == 0 {
+		logger.Info("🔄 Configuration reloaded, no runtime changes to apply")
+		return
+	}
+
+	r.current = &merged
+	r.apply(&merged)
+	logger.Infof("✅ Applied configuration changes: %s", strings.Join(applied, ", "))
+}
+
+// watchedDirs returns the directories holding the config file and .env
+func (r *Reloader) watchedDirs() []string {
+	dirs := []string{"."}
+	if r.configFile != "" {
+		if dir := filepath.Dir(r.configFile); dir != "." {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func (r *Reloader) isConfigSource(path string) bool {
+	if filepath.Clean(path) == ".env" {
+		return true
+	}
+	return r.configFile != "" && filepath.Clean(path) == filepath.Clean(r.configFile)
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReloadAppliesOnlyReloadableChanges(t *testing.T) {
+	vault := newVault(t)
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+vault+`
+log:
+  level: info
+http:
+  port: 8080
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var applied *Config
+	r := NewReloader(cfg, func(next *Config) { applied = next })
+
+	err = os.WriteFile(configFile, []byte(`
+vault_path: `+vault+`
+log:
+  level: debug
+watch:
+  debounce: 250ms
+  ignore: [templates, "*.excalidraw.md"]
+http:
+  port: 9090
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Reload()
+
+	if applied == nil {
+		t.Fatal("Expected reloadable changes to be applied")
+	}
+	if applied.LogLevel != "debug" {
+		t.Errorf("Expected log level 'debug', got '%s'", applied.LogLevel)
+	}
+	if applied.Debounce != 250*time.Millisecond {
+		t.Errorf("Expected debounce 250ms, got %s", applied.Debounce)
+	}
+	if len(applied.IgnorePatterns) != 2 || applied.IgnorePatterns[0] != "templates" {
+		t.Errorf("Expected ignore patterns [templates *.excalidraw.md], got %v", applied.IgnorePatterns)
+	}
+	if applied.HTTPPort != 8080 {
+		t.Errorf("Expected HTTP port to stay 8080 until restart, got %d", applied.HTTPPort)
+	}
+	if r.Current() != applied {
+		t.Error("Expected reloader to track the applied configuration")
+	}
+}
+
+func TestReloadKeepsCurrentConfigWhenInvalid(t *testing.T) {
+	vault := newVault(t)
+	configFile := writeConfigFile(t, "config.yaml", "vault_path: "+vault+"\n")
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	called := false
+	r := NewReloader(cfg, func(*Config) { called = true })
+
+	if err := os.WriteFile(configFile, []byte("vault_path: "+vault+"\nwatch:\n  debounce: soon\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r.Reload()
+
+	if called {
+		t.Error("Expected invalid configuration not to be applied")
+	}
+	if r.Current() != cfg {
+		t.Error("Expected current configuration to be kept")
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// referencePattern matches ${VAR} references in config file values
+var referencePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// source is one configuration layer. get returns the raw value of a name in that layer.
+type source struct {
+	name string
+	get  func(name string) (string, bool)
+	// expand enables ${VAR} references in the layer's values
+	expand bool
+}
+
+// resolve returns the value of a field from the highest precedence source that sets it,
+// and the name of that source. Besides the plain setting, each source may name a file
+// holding the value (Docker and Kubernetes secrets style): <ENV>_FILE for the environment
+// and .env, and <key>_file in the config file.
+func resolve(f field, sources []source, env func(name string) string) (string, string, error) {
+	value, from := f.def, "default"
+
+	for _, s := range sources {
+		name, fileName := f.env, f.env+"_FILE"
+		switch s.name {
+		case "file":
+			name, fileName = f.key, f.key+"_file"
+		case "flag":
+			name, fileName = f.flag, ""
+		}
+		if name == "" {
+			continue
+		}
+
+		v, direct := s.get(name)
+		path, indirect := "", false
+		if fileName != "" {
+			path, indirect = s.get(fileName)
+		}
+
+		switch {
+		case direct && indirect:
+			return "", s.name, fmt.Errorf("both %s and %s are set", name, fileName)
+		case indirect:
+			if s.expand {
+				var err error
+				if path, err = expandReferences(path, env); err != nil {
+					return "", s.name, err
+				}
+			}
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return "", s.name, err
+			}
+			value, from = secret, s.name
+		case direct:
+			if s.expand {
+				var err error
+				if v, err = expandReferences(v, env); err != nil {
+					return "", s.name, err
+				}
+			}
+			value, from = v, s.name
+		}
+	}
+
+	return value, from, nil
+}
+
+// expandReferences replaces ${VAR} with the value of VAR, failing on unset variables
+// so a typo doesn't silently turn into an empty secret
+func expandReferences(value string, env func(name string) string) (string, error) {
+	var missing []string
+	expanded := referencePattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := referencePattern.FindStringSubmatch(ref)[1]
+		v := env(name)
+		if v == "" {
+			missing = append(missing, name)
+		}
+		return v
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("references unset variable(s) %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// readSecretFile returns the contents of a secret file without the trailing newline
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// mapSource looks names up in a map; present but empty values count as set
+func mapSource(name string, values map[string]string, expand bool) source {
+	return source{
+		name: name,
+		get: func(key string) (string, bool) {
+			v, ok := values[key]
+			return v, ok
+		},
+		expand: expand,
+	}
+}
+
+// envSource looks names up with get; empty values count as unset
+func envSource(name string, get func(key string) string) source {
+	return source{
+		name: name,
+		get: func(key string) (string, bool) {
+			v := get(key)
+			return v, v != ""
+		},
+	}
+}
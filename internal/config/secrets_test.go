@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSecret(t *testing.T, value string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(value+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecretFromEnvFile(t *testing.T) {
+	t.Setenv("VAULT_PATH", newVault(t))
+	t.Setenv("ADMIN_TOKEN_FILE", writeSecret(t, "token-from-secret-file"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.AdminToken != "token-from-secret-file" {
+		t.Errorf("Expected admin token from file without trailing newline, got %q", cfg.AdminToken)
+	}
+	if cfg.Source("http.admin_token") != "env" {
+		t.Errorf("Expected admin token from env, got %s", cfg.Source("http.admin_token"))
+	}
+	if strings.Contains(cfg.String(), "token-from-secret-file") {
+		t.Errorf("Expected admin token to be redacted, got %s", cfg.String())
+	}
+}
+
+func TestConfigFileSecretFileAndReferences(t *testing.T) {
+	vault := newVault(t)
+	t.Setenv("TEST_SECRETS_DIR", filepath.Dir(writeSecret(t, "secret-access-key-value")))
+	t.Setenv("TEST_ACCESS_KEY", "AKIAEXAMPLE")
+
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+vault+`
+s3:
+  access_key_id: ${TEST_ACCESS_KEY}
+  secret_access_key_file: ${TEST_SECRETS_DIR}/secret
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.AWSAccessKeyID != "AKIAEXAMPLE" {
+		t.Errorf("Expected access key from reference, got %q", cfg.AWSAccessKeyID)
+	}
+	if cfg.AWSSecretAccessKey != "secret-access-key-value" {
+		t.Errorf("Expected secret key from file, got %q", cfg.AWSSecretAccessKey)
+	}
+
+	s := cfg.String()
+	for _, secret := range []string{"AKIAEXAMPLE", "secret-access-key-value"} {
+		if strings.Contains(s, secret) {
+			t.Errorf("Expected %s to be redacted, got %s", secret, s)
+		}
+	}
+}
+
+func TestSecretProblemsAreReported(t *testing.T) {
+	vault := newVault(t)
+	t.Setenv("ADMIN_TOKEN", "token-set-directly-too")
+	t.Setenv("ADMIN_TOKEN_FILE", writeSecret(t, "token-from-secret-file"))
+	t.Setenv("AWS_SECRET_ACCESS_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	configFile := writeConfigFile(t, "config.yaml", `
+vault_path: `+vault+`
+s3:
+  access_key_id: ${TEST_UNSET_VARIABLE}
+`)
+
+	_, err := LoadWithFlags([]string{"-config", configFile})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	for _, want := range []string{
+		"both ADMIN_TOKEN and ADMIN_TOKEN_FILE are set",
+		"failed to read secret file",
+		"references unset variable(s) TEST_UNSET_VARIABLE",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "token-set-directly-too") {
+		t.Errorf("Expected error not to leak the secret, got %v", err)
+	}
+}
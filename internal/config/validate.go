@@ -0,0 +1,298 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/options"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// awsRegionPattern matches region names such as us-east-1 or us-gov-west-1
+	awsRegionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+	// bucketPattern follows the S3 bucket naming rules for length and characters
+	bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+)
+
+// ValidationError lists every problem found in a configuration, so they can be
+// fixed together instead of one restart at a time.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// validate checks every field and the rules spanning several fields, returning
+// all problems found. Fields listed in unparsed already failed to parse and are skipped.
+func (c *Config) validate(unparsed map[string]bool) []string {
+	var problems []string
+	add := func(f field, err error) {
+		problems = append(problems, fmt.Sprintf("%s ($%s): %v", f.key, f.env, err))
+	}
+
+	for _, f := range fields {
+		if f.validate == nil || unparsed[f.key] {
+			continue
+		}
+		if err := f.validate(c); err != nil {
+			add(f, err)
+		}
+	}
+
+	// Cross-field rules
+	problems = append(problems, c.validateVaults()...)
+	if c.S3Enabled && c.S3Bucket == "" && len(c.Vaults) == 0 {
+		f, _ := lookupField("s3.bucket")
+		add(f, errors.New("required when s3.enabled is true"))
+	}
+	if (c.AWSAccessKeyID == "") != (c.AWSSecretAccessKey == "") {
+		f, _ := lookupField("s3.secret_access_key")
+		add(f, errors.New("s3.access_key_id and s3.secret_access_key must be set together"))
+	}
+
+	return problems
+}
+
+func validateVaultPath(c *Config) error {
+	// The vaults list replaces vault_path, see validateVaults
+	if len(c.Vaults) > 0 {
+		return nil
+	}
+	if c.VaultPath == "" {
+		return errors.New("required")
+	}
+
+	info, err := os.Stat(c.VaultPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("vault path does not exist: %s", c.VaultPath)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot access vault path: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("vault path is not a directory: %s", c.VaultPath)
+	}
+
+	info, err = os.Stat(filepath.Join(c.VaultPath, ".obsidian"))
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("%s does not look like an Obsidian vault (no .obsidian folder), open it in Obsidian first", c.VaultPath)
+	}
+	return nil
+}
+
+func validateBucket(c *Config) error {
+	if c.S3Bucket == "" {
+		return nil
+	}
+	if !bucketPattern.MatchString(c.S3Bucket) || strings.Contains(c.S3Bucket, "..") || net.ParseIP(c.S3Bucket) != nil {
+		return fmt.Errorf("invalid S3 bucket name %q: use 3-63 lowercase letters, digits, dots and hyphens", c.S3Bucket)
+	}
+	return nil
+}
+
+func validateRegion(c *Config) error {
+	if !awsRegionPattern.MatchString(c.AWSRegion) {
+		return fmt.Errorf("invalid AWS region %q, expected something like us-east-1", c.AWSRegion)
+	}
+	return nil
+}
+
+func validateLogLevel(c *Config) error {
+	if _, err := logrus.ParseLevel(strings.ToLower(c.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q, use debug, info, warn or error", c.LogLevel)
+	}
+	return nil
+}
+
+func validateLogFormat(c *Config) error {
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("invalid log format %q, use text or json", c.LogFormat)
+	}
+	return nil
+}
+
+// validateLogFile checks that the log file, or the closest existing parent folder, is writable
+func validateLogFile(c *Config) error {
+	if c.LogFile == "" {
+		return nil
+	}
+
+	if info, err := os.Stat(c.LogFile); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("log file is a directory: %s", c.LogFile)
+		}
+		file, err := os.OpenFile(c.LogFile, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("log file is not writable: %v", err)
+		}
+		return file.Close()
+	}
+
+	dir := filepath.Dir(c.LogFile)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("cannot create log directory, %s is a file", dir)
+			}
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return fmt.Errorf("no existing parent folder for %s", c.LogFile)
+		}
+		dir = parent
+	}
+
+	probe, err := os.CreateTemp(dir, ".obsidian-sync-*")
+	if err != nil {
+		return fmt.Errorf("log directory %s is not writable: %v", dir, err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+func validatePort(c *Config) error {
+	if c.HTTPPort < 1 || c.HTTPPort > 65535 {
+		return fmt.Errorf("port %d is out of range 1-65535", c.HTTPPort)
+	}
+	return nil
+}
+
+func validateDebounce(c *Config) error {
+	if c.Debounce <= 0 || c.Debounce > time.Minute {
+		return fmt.Errorf("debounce %s must be between 0 and 1m", c.Debounce)
+	}
+	return nil
+}
+
+func validateIgnorePatterns(c *Config) error {
+	for _, pattern := range c.IgnorePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func validateLayout(c *Config) error {
+	if c.S3Layout != options.LayoutPaths && c.S3Layout != options.LayoutContent {
+		return fmt.Errorf("invalid layout %q, use paths or content", c.S3Layout)
+	}
+	return nil
+}
+
+func validateHistoryVersions(c *Config) error {
+	if c.HistoryVersions < 0 {
+		return fmt.Errorf("history versions %d must not be negative", c.HistoryVersions)
+	}
+	return nil
+}
+
+func validateWatchMode(c *Config) error {
+	switch c.WatchMode {
+	case options.WatchAuto, options.WatchNative, options.WatchPoll:
+		return nil
+	}
+	return fmt.Errorf("invalid watch mode %q, use auto, native or poll", c.WatchMode)
+}
+
+func validateSymlinks(c *Config) error {
+	switch c.Symlinks {
+	case options.SymlinkIgnore, options.SymlinkFollow, options.SymlinkLink:
+		return nil
+	}
+	return fmt.Errorf("invalid symlink policy %q, use ignore, follow or link", c.Symlinks)
+}
+
+func validatePollInterval(c *Config) error {
+	if c.PollInterval < 100*time.Millisecond || c.PollInterval > time.Hour {
+		return fmt.Errorf("poll interval %s must be between 100ms and 1h", c.PollInterval)
+	}
+	return nil
+}
+
+func validateRescanInterval(c *Config) error {
+	if c.RescanInterval < 0 {
+		return fmt.Errorf("rescan interval %s must not be negative", c.RescanInterval)
+	}
+	if c.RescanInterval > 0 && c.RescanInterval < time.Minute {
+		return fmt.Errorf("rescan interval %s must be at least 1m, use watch.mode: poll to scan more often", c.RescanInterval)
+	}
+	return nil
+}
+
+func validateHistoryRetention(c *Config) error {
+	if c.HistoryRetention < 0 {
+		return fmt.Errorf("history retention %s must not be negative", c.HistoryRetention)
+	}
+	return nil
+}
+
+func validatePullInterval(c *Config) error {
+	if c.PullInterval < 0 {
+		return fmt.Errorf("pull interval %s must not be negative", c.PullInterval)
+	}
+	if c.PullInterval > 0 && c.S3Layout != options.LayoutContent {
+		return errors.New("pulling remote changes needs s3.layout: content")
+	}
+	return nil
+}
+
+func validateConflictPolicy(c *Config) error {
+	switch c.ConflictPolicy {
+	case options.ConflictLocalWins, options.ConflictRemoteWins, options.ConflictKeepBoth, options.ConflictMerge:
+		return nil
+	}
+	return fmt.Errorf("invalid conflict policy %q, use local-wins, remote-wins, keep-both or merge", c.ConflictPolicy)
+}
+
+func validateRedactMode(c *Config) error {
+	if c.RedactMode != options.RedactMask && c.RedactMode != options.RedactHash {
+		return fmt.Errorf("invalid redaction mode %q, use mask or hash", c.RedactMode)
+	}
+	// Unsalted hashes of short names are easily guessed
+	if c.RedactMode == options.RedactHash && c.RedactSalt == "" {
+		return errors.New("redaction mode hash needs redact.salt")
+	}
+	return nil
+}
+
+func validateRedactPatterns(c *Config) error {
+	for _, pattern := range c.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+func validateRedactPaths(c *Config) error {
+	for _, pattern := range c.RedactPaths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func validatePrivateFrontmatter(c *Config) error {
+	_, err := options.ParseFrontmatterRules(c.PrivateFrontmatter)
+	return err
+}
+
+func validateAdminToken(c *Config) error {
+	if c.AdminToken != "" && len(c.AdminToken) < 16 {
+		return errors.New("must be at least 16 characters long")
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultVaultID identifies the single vault configured through vault_path
+const defaultVaultID = "default"
+
+// vaultIDPattern keeps vault IDs usable in object keys, metrics and URLs
+var vaultIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// vaultKeys are the keys accepted in each entry of the vaults list
+var vaultKeys = map[string]bool{
+	"id":        true,
+	"path":      true,
+	"ignore":    true,
+	"s3.bucket": true,
+	"s3.prefix": true,
+}
+
+// Vault is one vault managed by the daemon, with its own watcher, filters and destination.
+type Vault struct {
+	ID   string
+	Path string
+	// IgnorePatterns are added to the global watch.ignore patterns
+	IgnorePatterns []string
+	// S3Bucket defaults to s3.bucket
+	S3Bucket string
+	// S3Prefix is prepended to object keys, "<id>/" unless set; "/" uploads to the bucket root
+	S3Prefix string
+	// prefixSet records whether S3Prefix was configured explicitly
+	prefixSet bool
+}
+
+// VaultDefinitions returns the vaults to sync: the vaults list from the config
+// file, or a single vault with ID "default" built from vault_path and s3.bucket.
+func (c *Config) VaultDefinitions() []Vault {
+	if len(c.Vaults) == 0 {
+		return []Vault{{
+			ID:       defaultVaultID,
+			Path:     c.VaultPath,
+			S3Bucket: c.S3Bucket,
+		}}
+	}
+
+	vaults := make([]Vault, len(c.Vaults))
+	for i, v := range c.Vaults {
+		if v.S3Bucket == "" {
+			v.S3Bucket = c.S3Bucket
+		}
+		if !v.prefixSet {
+			v.S3Prefix = v.ID + "/"
+		}
+		vaults[i] = v
+	}
+	return vaults
+}
+
+// splitVaults removes the vaults list from the raw config file and flattens each entry
+func splitVaults(raw map[string]interface{}) ([]map[string]string, error) {
+	list, exists := raw["vaults"]
+	if !exists {
+		return nil, nil
+	}
+	delete(raw, "vaults")
+
+	var entries []map[string]interface{}
+	switch l := list.(type) {
+	case []interface{}:
+		for _, item := range l {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("vaults must be a list of sections")
+			}
+			entries = append(entries, entry)
+		}
+	case []map[string]interface{}:
+		// TOML arrays of tables
+		entries = l
+	default:
+		return nil, errors.New("vaults must be a list of sections")
+	}
+
+	vaults := make([]map[string]string, 0, len(entries))
+	for i, entry := range entries {
+		values := make(map[string]string)
+		if err := flatten("", entry, values); err != nil {
+			return nil, fmt.Errorf("vaults[%d]: %v", i, err)
+		}
+
+		var unknown []string
+		for key := range values {
+			if !vaultKeys[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return nil, fmt.Errorf("vaults[%d]: unknown keys %s", i, strings.Join(unknown, ", "))
+		}
+
+		vaults = append(vaults, values)
+	}
+	return vaults, nil
+}
+
+// parseVault builds a vault from a flattened vaults entry, expanding ${VAR} references
+func parseVault(values map[string]string, env func(name string) string) (Vault, error) {
+	for key, value := range values {
+		expanded, err := expandReferences(value, env)
+		if err != nil {
+			return Vault{}, fmt.Errorf("%s %v", key, err)
+		}
+		values[key] = expanded
+	}
+
+	v := Vault{
+		ID:             values["id"],
+		Path:           values["path"],
+		IgnorePatterns: splitList(values["ignore"]),
+		S3Bucket:       values["s3.bucket"],
+	}
+	if prefix, ok := values["s3.prefix"]; ok {
+		v.S3Prefix = strings.TrimPrefix(prefix, "/")
+		v.prefixSet = true
+	}
+	return v, nil
+}
+
+// validateVaults checks the vaults list. Whether each vault folder exists is only
+// checked when its watcher starts, so one unavailable vault doesn't stop the others.
+func (c *Config) validateVaults() []string {
+	if len(c.Vaults) == 0 {
+		return nil
+	}
+
+	var problems []string
+	if c.VaultPath != "" {
+		problems = append(problems, "vault_path ($VAULT_PATH): set either vault_path or vaults, not both")
+	}
+
+	ids := make(map[string]bool)
+	for i, v := range c.VaultDefinitions() {
+		add := func(format string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf("vaults[%d]: ", i)+fmt.Sprintf(format, args...))
+		}
+
+		switch {
+		case v.ID == "":
+			add("id is required")
+		case !vaultIDPattern.MatchString(v.ID):
+			add("invalid id %q, use lowercase letters, digits, '-' and '_'", v.ID)
+		case ids[v.ID]:
+			add("duplicate id %q", v.ID)
+		}
+		ids[v.ID] = true
+
+		if v.Path == "" {
+			add("path is required")
+		} else if !filepath.IsAbs(v.Path) {
+			add("path must be absolute: %s", v.Path)
+		}
+
+		for _, pattern := range v.IgnorePatterns {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				add("invalid glob pattern %q", pattern)
+			}
+		}
+
+		if c.S3Enabled && v.S3Bucket == "" {
+			add("s3.bucket is required when s3.enabled is true and no global s3.bucket is set")
+		}
+		if err := validateBucket(&Config{S3Bucket: v.S3Bucket}); err != nil {
+			add("%v", err)
+		}
+	}
+
+	// Nested vaults would report every change twice
+	vaults := c.VaultDefinitions()
+	for i := range vaults {
+		for j := range vaults {
+			if i != j && vaults[i].Path != "" && isWithin(vaults[j].Path, vaults[i].Path) {
+				problems = append(problems, fmt.Sprintf("vaults[%d]: path %s overlaps with vault %q", j, vaults[j].Path, vaults[i].ID))
+			}
+		}
+	}
+
+	return problems
+}
+
+// compareVaults reports whether the vault definitions differ between a and b, and
+// if not, whether only their ignore patterns changed
+func compareVaults(a, b []Vault) (definitions, ignore bool) {
+	if len(a) != len(b) {
+		return true, false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		if x.ID != y.ID || x.Path != y.Path || x.S3Bucket != y.S3Bucket || x.S3Prefix != y.S3Prefix || x.prefixSet != y.prefixSet {
+			return true, false
+		}
+		if strings.Join(x.IgnorePatterns, ",") != strings.Join(y.IgnorePatterns, ",") {
+			ignore = true
+		}
+	}
+	return false, ignore
+}
+
+// isWithin reports whether path is dir or inside it
+func isWithin(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// vaultsString describes the vaults for Config.String
+func (c *Config) vaultsString() string {
+	parts := make([]string, 0, len(c.Vaults))
+	for _, v := range c.VaultDefinitions() {
+		part := fmt.Sprintf("%s:%s", v.ID, v.Path)
+		if v.S3Bucket != "" {
+			part += fmt.Sprintf(" -> s3://%s/%s", v.S3Bucket, v.S3Prefix)
+		}
+		parts = append(parts, part)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadVaultsList(t *testing.T) {
+	work, personal := newVault(t), newVault(t)
+	t.Setenv("PERSONAL_VAULT", personal)
+	configFile := writeConfigFile(t, "config.yaml", `
+s3:
+  bucket: shared-notes
+watch:
+  ignore: [templates]
+vaults:
+  - id: work
+    path: `+work+`
+    ignore: [drafts]
+  - id: personal
+    path: ${PERSONAL_VAULT}
+    s3:
+      bucket: personal-notes
+      prefix: /
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	vaults := cfg.VaultDefinitions()
+	if len(vaults) != 2 {
+		t.Fatalf("Expected 2 vaults, got %d", len(vaults))
+	}
+
+	tests := []struct {
+		got, want string
+	}{
+		{vaults[0].ID, "work"},
+		{vaults[0].Path, work},
+		{vaults[0].S3Bucket, "shared-notes"},
+		{vaults[0].S3Prefix, "work/"},
+		{strings.Join(vaults[0].IgnorePatterns, ","), "drafts"},
+		{vaults[1].Path, personal},
+		{vaults[1].S3Bucket, "personal-notes"},
+		{vaults[1].S3Prefix, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Expected '%s', got '%s'", tt.want, tt.got)
+		}
+	}
+}
+
+func TestLoadVaultsFromTOML(t *testing.T) {
+	vault := newVault(t)
+	configFile := writeConfigFile(t, "config.toml", `
+[[vaults]]
+id = "research"
+path = "`+vault+`"
+`)
+
+	cfg, err := LoadWithFlags([]string{"-config", configFile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if vaults := cfg.VaultDefinitions(); len(vaults) != 1 || vaults[0].ID != "research" {
+		t.Errorf("Expected the research vault, got %v", vaults)
+	}
+}
+
+func TestSingleVaultPathIsDefaultVault(t *testing.T) {
+	vault := newVault(t)
+	t.Setenv("VAULT_PATH", vault)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	vaults := cfg.VaultDefinitions()
+	if len(vaults) != 1 || vaults[0].ID != "default" || vaults[0].Path != vault || vaults[0].S3Prefix != "" {
+		t.Errorf("Expected a single default vault at %s without prefix, got %v", vault, vaults)
+	}
+}
+
+func TestLoadReportsVaultProblems(t *testing.T) {
+	work := newVault(t)
+	t.Setenv("VAULT_PATH", work)
+	configFile := writeConfigFile(t, "config.yaml", `
+s3:
+  enabled: true
+vaults:
+  - id: work
+    path: `+work+`
+  - id: work
+    path: `+filepath.Join(work, "nested")+`
+  - id: Bad ID
+    path: relative/path
+`)
+
+	_, err := LoadWithFlags([]string{"-config", configFile})
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a ValidationError, got %v", err)
+	}
+
+	expected := []string{
+		"set either vault_path or vaults, not both",
+		`vaults[1]: duplicate id "work"`,
+		`vaults[2]: invalid id "Bad ID"`,
+		"vaults[2]: path must be absolute",
+		`vaults[1]: path ` + filepath.Join(work, "nested") + ` overlaps with vault "work"`,
+		"vaults[0]: s3.bucket is required",
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownVaultKeys(t *testing.T) {
+	configFile := writeConfigFile(t, "config.yaml", `
+vaults:
+  - id: work
+    pth: /tmp
+`)
+
+	_, err := LoadWithFlags([]string{"-config", configFile})
+	if err == nil || !strings.Contains(err.Error(), "vaults[0]: unknown keys pth") {
+		t.Errorf("Expected unknown key error mentioning pth, got %v", err)
+	}
+}
@@ -0,0 +1,526 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/admin"
+	"github.com/aarangop/obsidian-sync/internal/config"
+	"github.com/aarangop/obsidian-sync/internal/encryption"
+	"github.com/aarangop/obsidian-sync/internal/events"
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/obsidian"
+	"github.com/aarangop/obsidian-sync/internal/pipeline"
+	"github.com/aarangop/obsidian-sync/internal/privacy"
+	"github.com/aarangop/obsidian-sync/internal/pull"
+	"github.com/aarangop/obsidian-sync/internal/redact"
+	"github.com/aarangop/obsidian-sync/internal/state"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+	"github.com/aarangop/obsidian-sync/internal/watcher"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// defaultRetryInterval is how long to wait before starting a failed vault again
+const defaultRetryInterval = 30 * time.Second
+
+// Daemon syncs every configured vault. Each vault has its own watcher, pipeline
+// and sinks, and a vault that fails to set up or start is retried without affecting the others.
+type Daemon struct {
+	vaults []*vault
+	hub    *events.Hub
+	// cfg is the latest configuration, used to set up failed vaults again
+	cfg atomic.Pointer[config.Config]
+	// keys encrypt the uploads, nil unless encryption.key_file is set
+	keys *encryption.KeyFile
+
+	// storesMu guards stores, the S3 stores by bucket shared by the vaults uploading to them
+	storesMu sync.Mutex
+	stores   map[string]*uploader.S3Store
+
+	// eventRedactor redacts the events published to the hub, nil unless redact.events is set
+	eventRedactor atomic.Pointer[redact.Redactor]
+
+	// plan records the bucket calls in dry-run mode instead of making them, nil otherwise
+	plan *uploader.Plan
+	// planFile is where the plan is exported, if anywhere
+	planFile *os.File
+
+	retryInterval time.Duration
+	pullInterval  time.Duration
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+// vault is the runtime state of one configured vault
+type vault struct {
+	def      config.Vault
+	watcher  watcher.FileWatcher
+	pipeline *pipeline.Pipeline
+	echoes   *echoes
+
+	// mu guards the parts below, made by Daemon.setup; events are dropped until ready is set
+	mu    sync.Mutex
+	ready atomic.Bool
+	// settings delivers changes to the .obsidian folder, nil unless syncing settings
+	settings *pipeline.Pipeline
+	privacy  *privacy.Filter
+	// store is the bucket the vault syncs to, nil unless S3 is enabled
+	store uploader.ObjectStore
+	// content is the uploader with the content layout, nil with other layouts
+	content *uploader.ContentUploader
+	// puller brings remote changes into the vault, nil unless pulling is enabled
+	puller *pull.Puller
+}
+
+// New sets up the vaults from the configuration. Events from every vault are
+// also published to the hub, stamped with their vault ID and redacted when redact.events is set.
+// A vault that fails to set up, e.g. because its sync record is damaged, is
+// set up again when the daemon starts it.
+func New(cfg *config.Config, hub *events.Hub) (*Daemon, error) {
+	d := &Daemon{
+		hub:           hub,
+		stores:        make(map[string]*uploader.S3Store),
+		retryInterval: defaultRetryInterval,
+		pullInterval:  cfg.PullInterval,
+		stop:          make(chan struct{}),
+	}
+	d.cfg.Store(cfg)
+	if err := d.setEventRedactor(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.DryRun {
+		if err := d.setPlan(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.S3Enabled && cfg.EncryptionKeyFile != "" {
+		var err error
+		if d.keys, err = encryption.OpenKeyFile(cfg.EncryptionKeyFile); err != nil {
+			return nil, err
+		}
+		keyring, _ := d.keys.Keyring()
+		logger.Infof("🔐 Encrypting uploads with key %s", keyring.ActiveKeyID())
+	}
+
+	for _, def := range cfg.VaultDefinitions() {
+		v := &vault{
+			def:      def,
+			watcher:  newWatcher(cfg, def.Path),
+			pipeline: pipeline.New(),
+			echoes:   newEchoes(),
+		}
+		v.watcher.SetVaultID(def.ID)
+		v.watcher.SetDebounce(cfg.Debounce)
+		v.watcher.SetIgnorePatterns(ignorePatterns(cfg, def))
+		v.watcher.SetSyncSettings(cfg.S3Enabled && cfg.SyncSettings)
+		if cfg.Symlinks != "" {
+			v.watcher.SetSymlinks(cfg.Symlinks)
+		}
+		v.watcher.OnEvent(func(event models.FileEvent) { d.handle(v, event) })
+
+		if err := d.setup(cfg, v); err != nil {
+			logger.ErrorWithFields("⚠️ Vault failed to set up, retrying", logger.Fields{"vault": def.ID, "retry_in": d.retryInterval.String(), "error": err})
+		}
+		d.vaults = append(d.vaults, v)
+	}
+
+	return d, nil
+}
+
+// setup makes the sinks of a vault and its puller. Nothing is kept when it
+// fails, so it can be tried again.
+func (d *Daemon) setup(cfg *config.Config, v *vault) error {
+	def := v.def
+	filter, err := privacy.New(cfg.PrivacyRules())
+	if err != nil {
+		return err
+	}
+
+	var sinks []pipeline.Sink
+	var content *uploader.ContentUploader
+	var puller *pull.Puller
+	var settings *pipeline.Pipeline
+	var store uploader.ObjectStore
+	if cfg.S3Enabled {
+		store, err = d.bucket(cfg, def)
+		if err != nil {
+			return err
+		}
+		if d.keys != nil {
+			encrypted := uploader.NewEncryptedStore(store, d.keys)
+			encrypted.SetAllowPlaintext(cfg.EncryptionAllowPlaintext)
+			store = encrypted
+		}
+		if cfg.SyncSettings {
+			settings = pipeline.New(uploader.NewSettingsBundle(store, def.Path, def.S3Prefix))
+		}
+		if cfg.S3Layout == uploader.LayoutContent {
+			content = uploader.NewContent(store, def.Path, def.S3Prefix)
+			content.SetHistory(cfg.HistoryPolicy())
+			sinks = append(sinks, content)
+
+			if cfg.PullInterval > 0 && d.plan != nil {
+				logger.InfoWithFields("⏭️ Not pulling remote changes in dry-run mode", logger.Fields{"vault": def.ID})
+			} else if cfg.PullInterval > 0 {
+				record, err := state.Open(filepath.Join(cfg.StateDir, def.ID+".json"))
+				if err != nil {
+					return err
+				}
+				content.SetRecord(record)
+				puller = pull.New(content, store, record, def.Path, def.S3Prefix)
+				puller.SetVaultID(def.ID)
+				puller.SetPolicy(cfg.ConflictPolicy)
+				puller.SetFilter(v.watcher.Syncs)
+				puller.OnApply(v.echoes.expect)
+				puller.OnResync(func(event models.FileEvent) { d.deliver(v, event) })
+			}
+		} else {
+			sinks = append(sinks, uploader.New(store, def.Path, def.S3Prefix))
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, sink := range sinks {
+		v.pipeline.AddSink(sink)
+	}
+	v.settings = settings
+	v.privacy = filter
+	v.store = store
+	v.content = content
+	v.puller = puller
+	v.ready.Store(true)
+	return nil
+}
+
+// newWatcher creates the watcher of the vault at path selected by watch.mode
+func newWatcher(cfg *config.Config, path string) watcher.FileWatcher {
+	if cfg.WatchMode == watcher.ModePoll {
+		return watcher.NewPoller(path, cfg.PollInterval)
+	}
+	w := watcher.New(path)
+	w.SetRescanInterval(cfg.RescanInterval)
+	if cfg.WatchMode == watcher.ModeAuto {
+		w.SetFallback(cfg.PollInterval)
+	}
+	return w
+}
+
+// handle drops the watcher's echoes of pulled changes and delivers the other events
+func (d *Daemon) handle(v *vault, event models.FileEvent) {
+	if !v.ready.Load() {
+		logger.DebugWithFields("⏭️ Vault not set up, dropping event", logger.Fields{"vault": v.def.ID, "path": event.FilePath})
+		return
+	}
+	if v.echoes.matches(event) {
+		return
+	}
+	d.deliver(v, event)
+}
+
+// deliver keeps private notes out of the vault's pipeline and the hub, then delivers the event to both.
+// Settings changes only go to the settings bundle.
+func (d *Daemon) deliver(v *vault, event models.FileEvent) {
+	if v.settings != nil && v.isSetting(event.FilePath) {
+		v.settings.Enqueue(event)
+		return
+	}
+	event, ok := v.privacy.Apply(event)
+	if !ok {
+		return
+	}
+	v.pipeline.Enqueue(event)
+	d.publish(event)
+}
+
+// isSetting reports whether a path is in the vault's .obsidian folder
+func (v *vault) isSetting(path string) bool {
+	rel, err := filepath.Rel(v.def.Path, path)
+	return err == nil && obsidian.IsSetting(rel)
+}
+
+// publish sends an event to the hub, redacted when configured
+func (d *Daemon) publish(event models.FileEvent) {
+	d.hub.Publish(d.eventRedactor.Load().Event(event))
+}
+
+// setEventRedactor builds the event redaction rules from the configuration
+func (d *Daemon) setEventRedactor(cfg *config.Config) error {
+	var r *redact.Redactor
+	if cfg.RedactEvents {
+		var err error
+		if r, err = redact.New(cfg.RedactRules()); err != nil {
+			return err
+		}
+	}
+	d.eventRedactor.Store(r)
+	return nil
+}
+
+// setPlan starts the dry run, exporting the plan to dry_run.output when set
+func (d *Daemon) setPlan(cfg *config.Config) error {
+	var out io.Writer
+	if cfg.DryRunOutput != "" {
+		file, err := os.Create(cfg.DryRunOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create dry-run output: %v", err)
+		}
+		d.planFile = file
+		out = file
+	}
+	d.plan = uploader.NewPlan(out)
+	logger.Info("📝 Dry run: recording what would be synced, nothing is uploaded or deleted")
+	if !cfg.S3Enabled {
+		logger.Warn("⚠️ S3 is disabled, so the dry run has nothing to plan")
+	}
+	return nil
+}
+
+// bucket returns the store a vault syncs to: its S3 bucket, or a recording stand-in in dry-run mode
+func (d *Daemon) bucket(cfg *config.Config, def config.Vault) (uploader.ObjectStore, error) {
+	if d.plan != nil {
+		return uploader.NewRecordingStore(d.plan, def.S3Bucket, def.ID), nil
+	}
+	store, err := d.store(cfg, def.S3Bucket)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// store returns the S3 store for a bucket, creating it on first use
+func (d *Daemon) store(cfg *config.Config, bucket string) (*uploader.S3Store, error) {
+	d.storesMu.Lock()
+	defer d.storesMu.Unlock()
+	if store, exists := d.stores[bucket]; exists {
+		return store, nil
+	}
+
+	store, err := uploader.NewS3Store(context.Background(), bucket, cfg.AWSRegion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 store for bucket %s: %v", bucket, err)
+	}
+	store.SetCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey)
+	d.stores[bucket] = store
+	return store, nil
+}
+
+// Start begins delivering and watching every vault in the background.
+func (d *Daemon) Start() {
+	for _, v := range d.vaults {
+		d.wg.Add(1)
+		go d.supervise(v)
+	}
+}
+
+// reconcile plans the changes a dry run would sync at startup by comparing the
+// vault with its bucket. The recording bucket starts empty, so every file is
+// planned as created.
+func (d *Daemon) reconcile(v *vault) error {
+	v.mu.Lock()
+	store := v.store
+	v.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+
+	files, err := uploader.ListFiles(context.Background(), store, v.def.S3Prefix, d.cfg.Load().S3Layout, time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to list the bucket: %v", err)
+	}
+	stored := make(map[string]string, len(files))
+	for _, file := range files {
+		stored[file.Path] = file.Hash
+	}
+
+	count, err := v.watcher.Reconcile(stored)
+	if err != nil {
+		return err
+	}
+	logger.InfoWithFields("🔎 Compared vault with its bucket", logger.Fields{"vault": v.def.ID, "changes": count})
+	return nil
+}
+
+// supervise sets up the vault if that failed before and starts delivering its
+// events, then runs its watcher, comparing the vault with its bucket first in a
+// dry run. Each step is tried again after a failure until the daemon stops.
+func (d *Daemon) supervise(v *vault) {
+	defer d.wg.Done()
+
+	for !v.ready.Load() {
+		if !d.retry() {
+			return
+		}
+		if err := d.setup(d.cfg.Load(), v); err != nil {
+			logger.ErrorWithFields("⚠️ Vault failed to set up, retrying", logger.Fields{"vault": v.def.ID, "retry_in": d.retryInterval.String(), "error": err})
+			continue
+		}
+		logger.InfoWithFields("✅ Vault set up", logger.Fields{"vault": v.def.ID})
+	}
+
+	v.mu.Lock()
+	v.pipeline.Start()
+	if v.settings != nil {
+		v.settings.Start()
+	}
+	if v.puller != nil {
+		v.puller.Start(d.pullInterval)
+	}
+	v.mu.Unlock()
+
+	// Only a dry run compares the vault at startup: in a normal run the privacy
+	// filter doesn't know yet which private notes were synced before
+	reconciled := d.plan == nil
+	for {
+		if !reconciled {
+			if err := d.reconcile(v); err != nil {
+				logger.WarnWithFields("⚠️ Failed to compare vault with its bucket", logger.Fields{"vault": v.def.ID, "error": err})
+			} else {
+				reconciled = true
+			}
+		}
+
+		// Start blocks until the watcher is stopped
+		err := v.watcher.Start()
+		if err == nil {
+			return
+		}
+
+		logger.ErrorWithFields("⚠️ Vault failed, retrying", logger.Fields{"vault": v.def.ID, "retry_in": d.retryInterval.String(), "error": err})
+		if !d.retry() {
+			return
+		}
+	}
+}
+
+// retry waits for the retry interval, returning false if the daemon stops first
+func (d *Daemon) retry() bool {
+	select {
+	case <-d.stop:
+		return false
+	case <-time.After(d.retryInterval):
+		return true
+	}
+}
+
+// Stop stops every watcher, delivers their pending events and waits for delivery to finish.
+func (d *Daemon) Stop() {
+	close(d.stop)
+	for _, v := range d.vaults {
+		if err := v.watcher.Stop(); err != nil {
+			logger.WarnWithFields("⚠️ Failed to stop watcher", logger.Fields{"vault": v.def.ID, "error": err})
+		}
+	}
+	// Vaults are no longer set up or started once every supervisor is done
+	d.wg.Wait()
+
+	for _, v := range d.vaults {
+		if v.puller != nil {
+			v.puller.Stop()
+		}
+		v.pipeline.Stop()
+		if v.settings != nil {
+			v.settings.Stop()
+		}
+	}
+
+	if d.plan != nil {
+		d.plan.LogSummary()
+	}
+	if d.planFile != nil {
+		if err := d.planFile.Close(); err != nil {
+			logger.Warnf("⚠️ Failed to write dry-run output: %v", err)
+		}
+	}
+}
+
+// Apply takes over the runtime-safe settings of a reloaded configuration.
+// Vaults that failed to set up use the whole configuration on their next try.
+func (d *Daemon) Apply(cfg *config.Config) {
+	d.cfg.Store(cfg)
+	defs := make(map[string]config.Vault)
+	for _, def := range cfg.VaultDefinitions() {
+		defs[def.ID] = def
+	}
+
+	for _, v := range d.vaults {
+		if def, exists := defs[v.def.ID]; exists {
+			v.def.IgnorePatterns = def.IgnorePatterns
+		}
+		v.watcher.SetDebounce(cfg.Debounce)
+		v.watcher.SetIgnorePatterns(ignorePatterns(cfg, v.def))
+
+		v.mu.Lock()
+		if v.privacy != nil {
+			if err := v.privacy.SetRules(cfg.PrivacyRules()); err != nil {
+				logger.Warnf("⚠️ Failed to apply private note rules: %v", err)
+			}
+		}
+		if v.content != nil {
+			v.content.SetHistory(cfg.HistoryPolicy())
+		}
+		if v.puller != nil {
+			v.puller.SetPolicy(cfg.ConflictPolicy)
+		}
+		v.mu.Unlock()
+	}
+
+	d.storesMu.Lock()
+	for _, store := range d.stores {
+		store.SetCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey)
+	}
+	d.storesMu.Unlock()
+
+	if err := d.setEventRedactor(cfg); err != nil {
+		logger.Warnf("⚠️ Failed to apply event redaction rules: %v", err)
+	}
+}
+
+// AdminVaults returns the vaults to control through the admin API.
+func (d *Daemon) AdminVaults() []admin.Vault {
+	vaults := make([]admin.Vault, 0, len(d.vaults))
+	for _, v := range d.vaults {
+		vaults = append(vaults, admin.Vault{ID: v.def.ID, Watcher: v.watcher, Pipeline: v.pipeline, Conflicts: conflictLog{d: d, v: v}})
+	}
+	return vaults
+}
+
+// conflictLog implements admin.ConflictLog, redacting the paths like those of events
+type conflictLog struct {
+	d *Daemon
+	v *vault
+}
+
+func (l conflictLog) Conflicts() []models.Conflict {
+	conflicts := l.v.Conflicts()
+	r := l.d.eventRedactor.Load()
+	for i, conflict := range conflicts {
+		conflicts[i] = r.Conflict(conflict)
+	}
+	return conflicts
+}
+
+// Conflicts returns the recent conflicts of the vault, none unless it pulls remote changes
+func (v *vault) Conflicts() []models.Conflict {
+	v.mu.Lock()
+	puller := v.puller
+	v.mu.Unlock()
+	if puller == nil {
+		return []models.Conflict{}
+	}
+	return puller.Conflicts()
+}
+
+// ignorePatterns combines the global ignore patterns with those of the vault
+func ignorePatterns(cfg *config.Config, def config.Vault) []string {
+	patterns := make([]string, 0, len(cfg.IgnorePatterns)+len(def.IgnorePatterns))
+	patterns = append(patterns, cfg.IgnorePatterns...)
+	return append(patterns, def.IgnorePatterns...)
+}
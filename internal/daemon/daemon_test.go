@@ -0,0 +1,338 @@
+package daemon
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/config"
+	"github.com/aarangop/obsidian-sync/internal/events"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// waitForEvent returns the first event for path, failing the test after a timeout
+func waitForEvent(t *testing.T, events <-chan models.FileEvent, path string) models.FileEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.FilePath == path {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Expected an event for %s, got none", path)
+		}
+	}
+}
+
+func TestFailedVaultDoesNotStopOthers(t *testing.T) {
+	work := t.TempDir()
+	personal := filepath.Join(t.TempDir(), "personal")
+
+	cfg := &config.Config{
+		Debounce: 10 * time.Millisecond,
+		Vaults: []config.Vault{
+			{ID: "work", Path: work},
+			{ID: "personal", Path: personal},
+		},
+	}
+
+	hub := events.NewHub()
+	_, stream, unsubscribe := hub.Subscribe(0)
+	defer unsubscribe()
+
+	d, err := New(cfg, hub)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	d.retryInterval = 50 * time.Millisecond
+	d.Start()
+	defer d.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The personal vault doesn't exist yet, work is synced regardless
+	note := filepath.Join(work, "note.md")
+	if err := os.WriteFile(note, []byte("# Work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitForEvent(t, stream, note); event.VaultID != "work" {
+		t.Errorf("Expected vault ID 'work', got '%s'", event.VaultID)
+	}
+
+	// Once the folder appears the personal vault starts on the next retry
+	if err := os.Mkdir(personal, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	note = filepath.Join(personal, "note.md")
+	if err := os.WriteFile(note, []byte("# Personal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitForEvent(t, stream, note); event.VaultID != "personal" {
+		t.Errorf("Expected vault ID 'personal', got '%s'", event.VaultID)
+	}
+}
+
+// fakeS3 serves S3 object requests from memory, pointing the AWS SDK at it for the test
+func fakeS3(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+			rw.Header().Set("ETag", strconv.Quote(strconv.Itoa(len(objects))))
+		case http.MethodGet:
+			body, exists := objects[r.URL.Path]
+			if !exists {
+				rw.WriteHeader(http.StatusNotFound)
+				io.WriteString(rw, "<Error><Code>NoSuchKey</Code></Error>")
+				return
+			}
+			rw.Write(body)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+}
+
+func TestVaultWithDamagedRecordDoesNotStopOthers(t *testing.T) {
+	fakeS3(t)
+	work := t.TempDir()
+	personal := t.TempDir()
+	stateDir := t.TempDir()
+	broken := filepath.Join(stateDir, "personal.json")
+	if err := os.WriteFile(broken, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Debounce:           10 * time.Millisecond,
+		S3Enabled:          true,
+		S3Bucket:           "notes",
+		AWSRegion:          "us-east-1",
+		AWSAccessKeyID:     "test",
+		AWSSecretAccessKey: "test",
+		S3Layout:           "content",
+		PullInterval:       time.Hour,
+		StateDir:           stateDir,
+		Vaults: []config.Vault{
+			{ID: "work", Path: work, S3Bucket: "notes", S3Prefix: "work/"},
+			{ID: "personal", Path: personal, S3Bucket: "notes", S3Prefix: "personal/"},
+		},
+	}
+
+	hub := events.NewHub()
+	_, stream, unsubscribe := hub.Subscribe(0)
+	defer unsubscribe()
+
+	d, err := New(cfg, hub)
+	if err != nil {
+		t.Fatalf("Expected the damaged record not to fail the daemon, got %v", err)
+	}
+	d.retryInterval = 50 * time.Millisecond
+	d.Start()
+	defer d.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	note := filepath.Join(work, "note.md")
+	if err := os.WriteFile(note, []byte("# Work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitForEvent(t, stream, note); event.VaultID != "work" {
+		t.Errorf("Expected vault ID 'work', got '%s'", event.VaultID)
+	}
+
+	// Once the record is repaired the personal vault is set up on the next retry
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	note = filepath.Join(personal, "note.md")
+	if err := os.WriteFile(note, []byte("# Personal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if event := waitForEvent(t, stream, note); event.VaultID != "personal" {
+		t.Errorf("Expected vault ID 'personal', got '%s'", event.VaultID)
+	}
+}
+
+func TestApplyCombinesIgnorePatterns(t *testing.T) {
+	vault := t.TempDir()
+	cfg := &config.Config{
+		Debounce:       10 * time.Millisecond,
+		IgnorePatterns: []string{"templates"},
+		Vaults:         []config.Vault{{ID: "work", Path: vault, IgnorePatterns: []string{"drafts"}}},
+	}
+
+	hub := events.NewHub()
+	_, stream, unsubscribe := hub.Subscribe(0)
+	defer unsubscribe()
+
+	d, err := New(cfg, hub)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	for _, dir := range []string{"templates", "drafts"} {
+		if err := os.Mkdir(filepath.Join(vault, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(vault, dir, "note.md"), []byte("# Ignored"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	note := filepath.Join(vault, "note.md")
+	if err := os.WriteFile(note, []byte("# Synced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := d.AdminVaults()[0].Watcher.Resync("")
+	if err != nil {
+		t.Fatalf("Failed to resync: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 file resynced with both patterns ignored, got %d", count)
+	}
+
+	// Dropping the vault pattern on reload makes its folder visible again
+	next := *cfg
+	next.Vaults = []config.Vault{{ID: "work", Path: vault}}
+	d.Apply(&next)
+
+	if count, _ := d.AdminVaults()[0].Watcher.Resync(""); count != 2 {
+		t.Errorf("Expected 2 files resynced after reload, got %d", count)
+	}
+	waitForEvent(t, stream, note)
+}
+
+func TestEchoesDropPulledChangesOnly(t *testing.T) {
+	e := newEchoes()
+	note := filepath.Join("vault", "note.md")
+
+	e.expect(note, "aa")
+	if !e.matches(models.FileEvent{EventType: models.EventModified, FilePath: note, Checksum: "aa"}) {
+		t.Error("Expected the pulled content to match")
+	}
+	if e.matches(models.FileEvent{EventType: models.EventModified, FilePath: note, Checksum: "aa"}) {
+		t.Error("Expected an echo to match only once")
+	}
+
+	// A local edit made before the echo arrived is synced
+	e.expect(note, "aa")
+	if e.matches(models.FileEvent{EventType: models.EventModified, FilePath: note, Checksum: "bb"}) {
+		t.Error("Expected different content not to match")
+	}
+
+	e.expect(note, "")
+	if !e.matches(models.FileEvent{EventType: models.EventDeleted, FilePath: note}) {
+		t.Error("Expected the pulled deletion to match")
+	}
+}
+
+func TestDryRunPlansExistingFilesAsCreated(t *testing.T) {
+	work := t.TempDir()
+	note := filepath.Join(work, "note.md")
+	if err := os.WriteFile(note, []byte("# Work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Debounce:  10 * time.Millisecond,
+		DryRun:    true,
+		S3Enabled: true,
+		S3Bucket:  "notes",
+		S3Layout:  "content",
+		Vaults:    []config.Vault{{ID: "work", Path: work, S3Bucket: "notes", S3Prefix: "work/"}},
+	}
+
+	hub := events.NewHub()
+	_, stream, unsubscribe := hub.Subscribe(0)
+	defer unsubscribe()
+
+	d, err := New(cfg, hub)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	d.Start()
+
+	// The recording bucket starts empty, so the note is missing from it
+	if event := waitForEvent(t, stream, note); event.EventType != models.EventCreated {
+		t.Errorf("Expected the existing note to be planned as created, got %s", event.EventType)
+	}
+	d.Stop()
+
+	// The blob and the manifest
+	if got := d.plan.Count(uploader.OpPut); got != 2 {
+		t.Errorf("Expected 2 planned uploads, got %d", got)
+	}
+}
+
+func TestNormalRunDoesNotSyncExistingFilesAtStartup(t *testing.T) {
+	fakeS3(t)
+	work := t.TempDir()
+	existing := filepath.Join(work, "existing.md")
+	if err := os.WriteFile(existing, []byte("# Existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Debounce:           10 * time.Millisecond,
+		S3Enabled:          true,
+		S3Bucket:           "notes",
+		AWSRegion:          "us-east-1",
+		AWSAccessKeyID:     "test",
+		AWSSecretAccessKey: "test",
+		S3Layout:           "content",
+		Vaults:             []config.Vault{{ID: "work", Path: work, S3Bucket: "notes", S3Prefix: "work/"}},
+	}
+
+	hub := events.NewHub()
+	_, stream, unsubscribe := hub.Subscribe(0)
+	defer unsubscribe()
+
+	d, err := New(cfg, hub)
+	if err != nil {
+		t.Fatalf("Failed to create daemon: %v", err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	note := filepath.Join(work, "note.md")
+	if err := os.WriteFile(note, []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-stream:
+			if event.FilePath == existing {
+				t.Fatalf("Expected the existing note not to be synced at startup, got %s", event.EventType)
+			}
+			if event.FilePath == note {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Expected an event for %s, got none", note)
+		}
+	}
+}
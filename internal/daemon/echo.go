@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// echoTimeout is how long a pulled change waits for its watcher event
+const echoTimeout = time.Minute
+
+// echoes remembers the changes pulled into a vault, so the watcher events they
+// cause aren't synced back as local changes
+type echoes struct {
+	mu      sync.Mutex
+	pending map[string]echo
+}
+
+type echo struct {
+	// hash is the pulled content hash, empty for a deletion
+	hash  string
+	until time.Time
+}
+
+func newEchoes() *echoes {
+	return &echoes{pending: make(map[string]echo)}
+}
+
+// expect records that path is about to be written with hash, or deleted when hash is empty.
+func (e *echoes) expect(path, hash string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[filepath.Clean(path)] = echo{hash: hash, until: time.Now().Add(echoTimeout)}
+}
+
+// matches reports whether the event is the echo of a pulled change. Any other
+// event for the path means it changed locally since, so the echo is forgotten.
+func (e *echoes) matches(event models.FileEvent) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	path := filepath.Clean(event.FilePath)
+	pulled, exists := e.pending[path]
+	if !exists {
+		return false
+	}
+	delete(e.pending, path)
+	if time.Now().After(pulled.until) {
+		return false
+	}
+	if pulled.hash == "" {
+		return event.EventType == models.EventDeleted
+	}
+	return event.EventType != models.EventDeleted && event.Checksum == pulled.hash
+}
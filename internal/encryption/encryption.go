@@ -0,0 +1,305 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Algorithm names the encryption in object metadata
+const Algorithm = "aes-256-gcm"
+
+const (
+	keySize = 32
+	// magic starts every encrypted object, followed by the version
+	magic   = "OSENC"
+	version = 1
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ErrUnknownKey is returned when an object was encrypted with a key missing from the key file
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the keys of a key file. The last key is active and encrypts new
+// objects; earlier keys are kept to decrypt objects written before a rotation.
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// ParseKeyring reads "<id> <base64 key>" lines; empty lines and lines starting with # are skipped.
+func ParseKeyring(data []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.Fields(text)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"<id> <base64 key>\"", line)
+		}
+		id, encoded := parts[0], parts[1]
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("line %d: invalid key ID %q", line, id)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key ID %q", line, id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("line %d: key %s must be %d base64-encoded bytes", line, id, keySize)
+		}
+		k.keys[id] = key
+		k.active = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if k.active == "" {
+		return nil, errors.New("no keys found")
+	}
+	return k, nil
+}
+
+// ActiveKeyID returns the ID of the key that encrypts new objects.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// Seal encrypts plaintext with a fresh data key, which is itself encrypted with
+// the active key. The result carries the key ID and wrapped data key, so it can
+// be decrypted with nothing but the key file.
+func (k *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+
+	wrapped, err := seal(k.keys[k.active], dataKey, []byte(k.active))
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: magic, version, key ID length and ID, wrapped data key length and key
+	var header bytes.Buffer
+	header.WriteString(magic)
+	header.WriteByte(version)
+	header.WriteByte(byte(len(k.active)))
+	header.WriteString(k.active)
+	header.WriteByte(byte(len(wrapped)))
+	header.Write(wrapped)
+
+	// The header is authenticated along with the content
+	body, err := seal(dataKey, plaintext, header.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(header.Bytes(), body...), nil
+}
+
+// Open decrypts an object produced by Seal.
+func (k *Keyring) Open(data []byte) ([]byte, error) {
+	id, wrapped, body, err := parseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	key, exists := k.keys[id]
+	if !exists {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	dataKey, err := open(key, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with key %s: %v", id, err)
+	}
+
+	plaintext, err := open(dataKey, body, data[:len(data)-len(body)])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	return plaintext, nil
+}
+
+// IsEncrypted reports whether data starts like an object produced by Seal.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// KeyID returns the ID of the key an object was encrypted with.
+func KeyID(data []byte) (string, error) {
+	id, _, _, err := parseHeader(data)
+	return id, err
+}
+
+func parseHeader(data []byte) (id string, wrapped, body []byte, err error) {
+	if !IsEncrypted(data) {
+		return "", nil, nil, errors.New("not an encrypted object")
+	}
+	rest := data[len(magic):]
+	if len(rest) < 2 || rest[0] != version {
+		return "", nil, nil, errors.New("unsupported encryption format")
+	}
+
+	idLen := int(rest[1])
+	rest = rest[2:]
+	if len(rest) < idLen+1 {
+		return "", nil, nil, errors.New("truncated encryption header")
+	}
+	id, rest = string(rest[:idLen]), rest[idLen:]
+
+	wrappedLen := int(rest[0])
+	rest = rest[1:]
+	if len(rest) < wrappedLen {
+		return "", nil, nil, errors.New("truncated encryption header")
+	}
+	return id, rest[:wrappedLen], rest[wrappedLen:], nil
+}
+
+// seal encrypts with AES-GCM, prefixing the random nonce
+func seal(key, plaintext, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(key, data, additional []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], additional)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyFile loads a keyring from disk and reloads it when the file changes, so a
+// rotated key is picked up without a restart.
+type KeyFile struct {
+	path string
+
+	mu      sync.Mutex
+	keyring *Keyring
+	modTime time.Time
+	size    int64
+}
+
+// OpenKeyFile reads the key file at path.
+func OpenKeyFile(path string) (*KeyFile, error) {
+	f := &KeyFile{path: path}
+	if _, err := f.Keyring(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the location of the key file.
+func (f *KeyFile) Path() string {
+	return f.path
+}
+
+// Keyring returns the current keys, reading the file again if it changed.
+// When the changed file can't be read, the previous keys are kept along with the error.
+func (f *KeyFile) Keyring() (*Keyring, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return f.keyring, fmt.Errorf("failed to read key file %s: %v", f.path, err)
+	}
+	if f.keyring != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.keyring, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return f.keyring, fmt.Errorf("failed to read key file %s: %v", f.path, err)
+	}
+	keyring, err := ParseKeyring(data)
+	if err != nil {
+		return f.keyring, fmt.Errorf("invalid key file %s: %v", f.path, err)
+	}
+
+	f.keyring, f.modTime, f.size = keyring, info.ModTime(), info.Size()
+	return keyring, nil
+}
+
+// AddKey generates a key and appends it to the key file, creating the file if
+// needed. The new key becomes active; older keys stay to decrypt existing objects.
+func AddKey(path string) (string, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read key file %s: %v", path, err)
+	}
+	if len(existing) > 0 {
+		if _, err := ParseKeyring(existing); err != nil {
+			return "", fmt.Errorf("invalid key file %s: %v", path, err)
+		}
+	}
+
+	key := make([]byte, keySize)
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate key ID: %v", err)
+	}
+	id := time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(suffix)
+
+	var b bytes.Buffer
+	if len(existing) == 0 {
+		b.WriteString("# obsidian-sync encryption keys. The last key encrypts new uploads,\n")
+		b.WriteString("# keep the others to decrypt older objects. Back this file up.\n")
+	} else if !bytes.HasSuffix(existing, []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	fmt.Fprintf(&b, "%s %s\n", id, base64.StdEncoding.EncodeToString(key))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open key file %s: %v", path, err)
+	}
+	if _, err := file.Write(b.Bytes()); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write key file %s: %v", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write key file %s: %v", path, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newKeyFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if _, err := AddKey(path); err != nil {
+		t.Fatalf("Failed to create key file: %v", err)
+	}
+	return path
+}
+
+func loadKeyring(t *testing.T, path string) *Keyring {
+	t.Helper()
+	keys, err := OpenKeyFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open key file: %v", err)
+	}
+	keyring, err := keys.Keyring()
+	if err != nil {
+		t.Fatalf("Failed to read keys: %v", err)
+	}
+	return keyring
+}
+
+func TestSealAndOpen(t *testing.T) {
+	keyring := loadKeyring(t, newKeyFile(t))
+	plaintext := []byte("# Diary\n\nDear diary")
+
+	sealed, err := keyring.Seal(plaintext)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("Expected the sealed object not to contain the plaintext")
+	}
+	if id, _ := KeyID(sealed); id != keyring.ActiveKeyID() {
+		t.Errorf("Expected key ID %s, got %s", keyring.ActiveKeyID(), id)
+	}
+
+	opened, err := keyring.Open(sealed)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Expected %q, got %q", plaintext, opened)
+	}
+
+	// Any change to the header or content is detected
+	sealed[len(sealed)-1] ^= 1
+	if _, err := keyring.Open(sealed); err == nil {
+		t.Error("Expected tampered content to fail decryption")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	path := newKeyFile(t)
+	keys, err := OpenKeyFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open key file: %v", err)
+	}
+	before, _ := keys.Keyring()
+	old, err := before.Seal([]byte("written before the rotation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := AddKey(path)
+	if err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+
+	// The key file is reloaded and the new key is active
+	after, err := keys.Keyring()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if after.ActiveKeyID() != id {
+		t.Errorf("Expected active key %s, got %s", id, after.ActiveKeyID())
+	}
+
+	// Objects written with the old key still open
+	if _, err := after.Open(old); err != nil {
+		t.Errorf("Expected the old object to open after rotation, got %v", err)
+	}
+
+	// Without the old key they don't
+	other := loadKeyring(t, newKeyFile(t))
+	if _, err := other.Open(old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected key file mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"# only a comment\n",
+		"key1\n",
+		"key1 not-base64!\n",
+		"key1 c2hvcnQ=\n",
+		"bad/id QUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUE=\n",
+		"key1 QUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUE=\nkey1 QUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUE=\n",
+	}
+
+	for i, data := range tests {
+		if _, err := ParseKeyring([]byte(data)); err == nil {
+			t.Errorf("Test %d: expected an error for %q", i, data)
+		}
+	}
+}
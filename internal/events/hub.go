@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// log is the api component logger
+var log = logger.Component("api")
+
+const (
+	// historySize is how many recent events are kept for subscribers resuming from a sequence number
+	historySize = 1000
+	// subscriberBuffer is how far a subscriber may fall behind before it is disconnected
+	subscriberBuffer = 256
+)
+
+// Hub numbers file events and fans them out to live subscribers.
+type Hub struct {
+	mu          sync.Mutex
+	sequence    uint64
+	history     []models.FileEvent
+	subscribers map[chan models.FileEvent]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[chan models.FileEvent]struct{}),
+	}
+}
+
+// Publish assigns the next sequence number to the event and sends it to every
+// subscriber. It has the signature of a watcher.EventHandler and never blocks:
+// subscribers that can't keep up are disconnected and must resume.
+func (h *Hub) Publish(event models.FileEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sequence++
+	event.Sequence = h.sequence
+
+	h.history = append(h.history, event)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warnf("⚠️ Disconnecting slow event subscriber at sequence %d", event.Sequence)
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns the buffered events with a sequence number greater than
+// since, and a channel receiving every event published afterwards. The channel
+// is closed when the subscriber falls behind or unsubscribe is called.
+func (h *Hub) Subscribe(since uint64) (backlog []models.FileEvent, events <-chan models.FileEvent, unsubscribe func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, event := range h.history {
+		if event.Sequence > since {
+			backlog = append(backlog, event)
+		}
+	}
+
+	ch := make(chan models.FileEvent, subscriberBuffer)
+	h.subscribers[ch] = struct{}{}
+
+	unsubscribe = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, exists := h.subscribers[ch]; exists {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return backlog, ch, unsubscribe
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestSubscribeReturnsBacklogAfterSequence(t *testing.T) {
+	h := NewHub()
+	for i := 0; i < 5; i++ {
+		h.Publish(models.FileEvent{EventType: models.EventModified, FilePath: "/vault/a.md"})
+	}
+
+	backlog, events, unsubscribe := h.Subscribe(3)
+	defer unsubscribe()
+
+	if len(backlog) != 2 {
+		t.Fatalf("Expected 2 backlog events, got %d", len(backlog))
+	}
+	if backlog[0].Sequence != 4 || backlog[1].Sequence != 5 {
+		t.Errorf("Expected sequences 4 and 5, got %d and %d", backlog[0].Sequence, backlog[1].Sequence)
+	}
+
+	h.Publish(models.FileEvent{EventType: models.EventDeleted, FilePath: "/vault/a.md"})
+	event := <-events
+	if event.Sequence != 6 {
+		t.Errorf("Expected live event with sequence 6, got %d", event.Sequence)
+	}
+}
+
+func TestSlowSubscriberIsDisconnected(t *testing.T) {
+	h := NewHub()
+	_, events, unsubscribe := h.Subscribe(0)
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		h.Publish(models.FileEvent{EventType: models.EventModified, FilePath: "/vault/a.md"})
+	}
+
+	count := 0
+	for range events {
+		count++
+	}
+	if count != subscriberBuffer {
+		t.Errorf("Expected %d buffered events before disconnect, got %d", subscriberBuffer, count)
+	}
+}
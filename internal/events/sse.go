@@ -0,0 +1,148 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// heartbeatInterval keeps idle connections from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+// filter selects which events a client receives
+type filter struct {
+	vault  string
+	prefix string
+	types  map[models.EventType]bool
+}
+
+func (f filter) matches(event models.FileEvent) bool {
+	if f.vault != "" && event.VaultID != f.vault {
+		return false
+	}
+
+	if len(f.types) > 0 && !f.types[event.EventType] {
+		return false
+	}
+
+	if f.prefix != "" {
+		rel, err := filepath.Rel(event.VaultPath, event.FilePath)
+		if err != nil || !strings.HasPrefix(filepath.ToSlash(rel), f.prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServeHTTP streams events as Server-Sent Events. Supported query parameters:
+//   - vault: only events from the vault with this ID
+//   - prefix: only events for paths (relative to the vault) starting with this prefix
+//   - type: comma-separated event types, e.g. file_created,file_deleted
+//   - since: resume after this sequence number; the Last-Event-ID header works too.
+//     Without either, only events published after connecting are sent
+func (h *Hub) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	f := filter{
+		vault:  query.Get("vault"),
+		prefix: strings.TrimPrefix(query.Get("prefix"), "/"),
+		types:  make(map[models.EventType]bool),
+	}
+	if types := query.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			f.types[models.EventType(strings.TrimSpace(t))] = true
+		}
+	}
+
+	since, resume, err := parseSince(query.Get("since"), r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	backlog, events, unsubscribe := h.Subscribe(since)
+	defer unsubscribe()
+	if !resume {
+		// A new client only wants what happens from now on
+		backlog = nil
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+
+	if since > 0 && len(backlog) > 0 && backlog[0].Sequence > since+1 {
+		fmt.Fprintf(rw, ": events %d to %d are no longer available\n\n", since+1, backlog[0].Sequence-1)
+	}
+
+	for _, event := range backlog {
+		if f.matches(event) {
+			writeEvent(rw, event)
+		}
+	}
+	flusher.Flush()
+
+	log.Debugf("📡 Event stream opened by %s (since: %d)", r.RemoteAddr, since)
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Debugf("📡 Event stream closed by %s", r.RemoteAddr)
+			return
+		case event, ok := <-events:
+			if !ok {
+				return // Too slow, the client resumes with Last-Event-ID
+			}
+			if f.matches(event) {
+				writeEvent(rw, event)
+				flusher.Flush()
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(rw, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// parseSince returns the sequence number to resume after, and whether the client asked to resume at all
+func parseSince(param, lastEventID string) (uint64, bool, error) {
+	value := param
+	if value == "" {
+		value = lastEventID
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	since, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid sequence number: %s", value)
+	}
+	return since, true, nil
+}
+
+func writeEvent(rw http.ResponseWriter, event models.FileEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.WarnWithFields("⚠️ Failed to encode event", logger.Fields{"path": event.FilePath, "error": err})
+		return
+	}
+	fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, event.EventType, data)
+}
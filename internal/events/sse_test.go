@@ -0,0 +1,120 @@
+package events
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// readEventIDs reads SSE frames from the stream until n event ids have been seen
+func readEventIDs(t *testing.T, scanner *bufio.Scanner, n int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < n && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) < n {
+		t.Fatalf("Expected %d events, got %d (%v)", n, len(ids), scanner.Err())
+	}
+	return ids
+}
+
+func TestEventStreamFiltersAndResumes(t *testing.T) {
+	h := NewHub()
+	h.Publish(models.FileEvent{EventType: models.EventCreated, VaultPath: "/vault", FilePath: "/vault/daily/a.md"})
+	h.Publish(models.FileEvent{EventType: models.EventCreated, VaultPath: "/vault", FilePath: "/vault/projects/b.md"})
+	h.Publish(models.FileEvent{EventType: models.EventDeleted, VaultPath: "/vault", FilePath: "/vault/daily/c.md"})
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?prefix=daily/&type=file_created,file_modified&since=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected content type text/event-stream, got %s", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	if ids := readEventIDs(t, scanner, 1); ids[0] != "1" {
+		t.Errorf("Expected backlog event 1, got %s", ids[0])
+	}
+
+	// Live events are filtered the same way
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Publish(models.FileEvent{EventType: models.EventModified, VaultPath: "/vault", FilePath: "/vault/projects/b.md"})
+		h.Publish(models.FileEvent{EventType: models.EventModified, VaultPath: "/vault", FilePath: "/vault/daily/a.md"})
+	}()
+
+	if ids := readEventIDs(t, scanner, 1); ids[0] != "5" {
+		t.Errorf("Expected live event 5, got %s", ids[0])
+	}
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	h := NewHub()
+	for i := 0; i < 3; i++ {
+		h.Publish(models.FileEvent{EventType: models.EventModified, VaultPath: "/vault", FilePath: "/vault/a.md"})
+	}
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ids := readEventIDs(t, bufio.NewScanner(resp.Body), 1); ids[0] != "3" {
+		t.Errorf("Expected to resume at event 3, got %s", ids[0])
+	}
+}
+
+func TestEventStreamWithoutResumePointSendsLiveEventsOnly(t *testing.T) {
+	h := NewHub()
+	for i := 0; i < 3; i++ {
+		h.Publish(models.FileEvent{EventType: models.EventModified, VaultPath: "/vault", FilePath: "/vault/a.md"})
+	}
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Publish(models.FileEvent{EventType: models.EventCreated, VaultPath: "/vault", FilePath: "/vault/b.md"})
+	}()
+
+	if ids := readEventIDs(t, bufio.NewScanner(resp.Body), 1); ids[0] != "4" {
+		t.Errorf("Expected the live event 4 without the backlog, got %s", ids[0])
+	}
+}
+
+func TestEventStreamRejectsInvalidSequence(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHub().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?since=abc", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
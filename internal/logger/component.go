@@ -0,0 +1,242 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// componentKey is the entry field naming the component that logged it
+const componentKey = "component"
+
+// Components are the names of the component loggers whose level can be set on its own
+var Components = []string{"watcher", "pipeline", "uploader", "api", "server"}
+
+var (
+	// levelsMu guards the global and per-component levels
+	levelsMu        sync.RWMutex
+	globalLevel     = logrus.InfoLevel
+	componentLevels = make(map[string]logrus.Level)
+)
+
+// Logger logs on behalf of one component. Its entries carry a component field,
+// and its level can be overridden with SetComponentLevels.
+type Logger struct {
+	component string
+}
+
+// Component returns the logger for a component, e.g. "watcher".
+func Component(name string) *Logger {
+	return &Logger{component: name}
+}
+
+func (l *Logger) Debug(args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.DebugLevel); entry != nil {
+		entry.Debug(args...)
+	}
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.DebugLevel); entry != nil {
+		entry.Debugf(format, args...)
+	}
+}
+
+func (l *Logger) Info(args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.InfoLevel); entry != nil {
+		entry.Info(args...)
+	}
+}
+
+func (l *Logger) Infof(format string, args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.InfoLevel); entry != nil {
+		entry.Infof(format, args...)
+	}
+}
+
+func (l *Logger) Warn(args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.WarnLevel); entry != nil {
+		entry.Warn(args...)
+	}
+}
+
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.WarnLevel); entry != nil {
+		entry.Warnf(format, args...)
+	}
+}
+
+func (l *Logger) Error(args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.ErrorLevel); entry != nil {
+		entry.Error(args...)
+	}
+}
+
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	if entry := getLogEntryWithCaller(l.component, logrus.ErrorLevel); entry != nil {
+		entry.Errorf(format, args...)
+	}
+}
+
+func (l *Logger) InfoWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller(l.component, logrus.InfoLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Info(message)
+	}
+}
+
+func (l *Logger) DebugWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller(l.component, logrus.DebugLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Debug(message)
+	}
+}
+
+func (l *Logger) WarnWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller(l.component, logrus.WarnLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Warn(message)
+	}
+}
+
+func (l *Logger) ErrorWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller(l.component, logrus.ErrorLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Error(message)
+	}
+}
+
+// SetComponentLevels replaces the per-component level overrides, e.g.
+// {"watcher": "warn"}. Components without an override use the global level.
+func SetComponentLevels(levels map[string]string) error {
+	parsed := make(map[string]logrus.Level, len(levels))
+	for component, level := range levels {
+		l, err := parseComponentLevel(component, level)
+		if err != nil {
+			return err
+		}
+		parsed[component] = l
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	componentLevels = parsed
+	updateLogrusLevel()
+	return nil
+}
+
+// SetComponentLevel overrides the level of one component; an empty level
+// removes the override so the component follows the global level again.
+func SetComponentLevel(component, level string) error {
+	if level == "" {
+		if !isComponent(component) {
+			return fmt.Errorf("unknown component %q, use one of %s", component, strings.Join(Components, ", "))
+		}
+		levelsMu.Lock()
+		defer levelsMu.Unlock()
+		delete(componentLevels, component)
+		updateLogrusLevel()
+		return nil
+	}
+
+	l, err := parseComponentLevel(component, level)
+	if err != nil {
+		return err
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	componentLevels[component] = l
+	updateLogrusLevel()
+	return nil
+}
+
+// Levels returns the global level and the per-component overrides.
+func Levels() (string, map[string]string) {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+
+	levels := make(map[string]string, len(componentLevels))
+	for component, level := range componentLevels {
+		levels[component] = level.String()
+	}
+	return globalLevel.String(), levels
+}
+
+// ParseComponentLevels parses overrides in the form "watcher=warn,uploader=debug".
+func ParseComponentLevels(value string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		component, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected component=level, got %q", item)
+		}
+		component, level = strings.TrimSpace(component), strings.ToLower(strings.TrimSpace(level))
+		if _, err := parseComponentLevel(component, level); err != nil {
+			return nil, err
+		}
+		levels[component] = level
+	}
+	return levels, nil
+}
+
+// FormatComponentLevels is the inverse of ParseComponentLevels, sorted by component.
+func FormatComponentLevels(levels map[string]string) string {
+	items := make([]string, 0, len(levels))
+	for component, level := range levels {
+		items = append(items, component+"="+level)
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func parseComponentLevel(component, level string) (logrus.Level, error) {
+	if !isComponent(component) {
+		return 0, fmt.Errorf("unknown component %q, use one of %s", component, strings.Join(Components, ", "))
+	}
+	l, err := logrus.ParseLevel(strings.ToLower(level))
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q for %s, use debug, info, warn or error", level, component)
+	}
+	return l, nil
+}
+
+func isComponent(name string) bool {
+	for _, component := range Components {
+		if component == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether a component, or the global logger for "", logs at level
+func enabled(component string, level logrus.Level) bool {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+
+	effective, exists := componentLevels[component]
+	if !exists {
+		effective = globalLevel
+	}
+	return level <= effective
+}
+
+// updateLogrusLevel lets logrus through at the most verbose level in use, the
+// finer filtering happens in enabled. levelsMu must be held.
+func updateLogrusLevel() {
+	if Log == nil {
+		return
+	}
+
+	level := globalLevel
+	for _, l := range componentLevels {
+		if l > level {
+			level = l
+		}
+	}
+	Log.SetLevel(level)
+}
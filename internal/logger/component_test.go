@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestComponentLevelOverridesGlobalLevel(t *testing.T) {
+	SetLevel("info")
+	if err := SetComponentLevels(map[string]string{"watcher": "warn", "uploader": "debug"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer SetComponentLevels(nil)
+
+	tests := []struct {
+		component string
+		level     logrus.Level
+		want      bool
+	}{
+		{"watcher", logrus.InfoLevel, false},
+		{"watcher", logrus.WarnLevel, true},
+		{"uploader", logrus.DebugLevel, true},
+		{"server", logrus.DebugLevel, false},
+		{"server", logrus.InfoLevel, true},
+		{"", logrus.DebugLevel, false},
+	}
+	for _, tt := range tests {
+		if got := enabled(tt.component, tt.level); got != tt.want {
+			t.Errorf("Expected %q at %s enabled=%t, got %t", tt.component, tt.level, tt.want, got)
+		}
+	}
+
+	// Resetting the override makes the watcher follow the global level again
+	if err := SetComponentLevel("watcher", ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !enabled("watcher", logrus.InfoLevel) {
+		t.Error("Expected watcher to log at info after resetting its level")
+	}
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels("watcher=warn, uploader=DEBUG")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := FormatComponentLevels(levels); got != "uploader=debug,watcher=warn" {
+		t.Errorf("Expected 'uploader=debug,watcher=warn', got '%s'", got)
+	}
+
+	for _, value := range []string{"watcher", "disk=info", "watcher=loud"} {
+		if _, err := ParseComponentLevels(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
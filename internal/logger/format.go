@@ -0,0 +1,228 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/redact"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// callerKey is the entry field carrying the caller's file:line, rendered separately by the formatters
+	callerKey = "caller_info"
+	// funcKey is the entry field carrying the caller's function, only rendered in JSON
+	funcKey = "caller_func"
+)
+
+// pathFields are the fields holding a single file path or object key
+var pathFields = map[string]bool{"path": true, "root": true, "key": true, "copy": true, "target": true}
+
+// redactor holds the redaction rules applied to every entry, nil when disabled
+var redactor atomic.Pointer[redact.Redactor]
+
+// SetRedactor sets the rules that remove sensitive text from log output; nil disables redaction.
+func SetRedactor(r *redact.Redactor) {
+	redactor.Store(r)
+}
+
+// levelColors are the ANSI colors of the level names on terminals
+var levelColors = map[logrus.Level]int{
+	logrus.TraceLevel: 37,
+	logrus.DebugLevel: 37,
+	logrus.InfoLevel:  36,
+	logrus.WarnLevel:  33,
+	logrus.ErrorLevel: 31,
+	logrus.FatalLevel: 31,
+	logrus.PanicLevel: 31,
+}
+
+// CustomFormatter renders entries as "LEVEL [timestamp] caller: message key=value ...",
+// with fields sorted by key. Colors is only meant for terminals.
+type CustomFormatter struct {
+	Colors bool
+}
+
+// Format renders a single log entry
+func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	caller, _, fields := splitCaller(entry.Data)
+	message := redactEntry(entry.Message, fields)
+
+	// Create the custom format: LEVEL [timestamp] caller: message
+	levelText := fmt.Sprintf("%-7s", strings.ToUpper(entry.Level.String()))
+	if f.Colors {
+		levelText = fmt.Sprintf("\x1b[%dm%s\x1b[0m", levelColors[entry.Level], levelText)
+	}
+	timestamp := entry.Time.Format("2006-01-02 15:04:05")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s[%s] %s: %s", levelText, timestamp, caller, message)
+
+	// Add fields in a stable order
+	for _, key := range sortedKeys(fields) {
+		fmt.Fprintf(&b, " %s=%v", key, fields[key])
+	}
+
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// JSONFormatter renders entries as one JSON object per line: time, level, caller,
+// func and msg first, then the fields sorted by key.
+type JSONFormatter struct{}
+
+// Format renders a single log entry
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	caller, function, fields := splitCaller(entry.Data)
+	message := redactEntry(entry.Message, fields)
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	writeJSONField(&b, "time", entry.Time.Format(time.RFC3339Nano), true)
+	writeJSONField(&b, "level", entry.Level.String(), false)
+	writeJSONField(&b, "caller", caller, false)
+	if function != "" {
+		writeJSONField(&b, "func", function, false)
+	}
+	writeJSONField(&b, "msg", message, false)
+
+	for _, key := range sortedKeys(fields) {
+		name := key
+		// Keep fields from overwriting the standard keys
+		switch key {
+		case "time", "level", "caller", "func", "msg":
+			name = "fields." + key
+		}
+		writeJSONField(&b, name, fields[key], false)
+	}
+
+	b.WriteString("}\n")
+	return b.Bytes(), nil
+}
+
+func writeJSONField(b *bytes.Buffer, key string, value interface{}, first bool) {
+	if err, ok := value.(error); ok {
+		value = err.Error()
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	if !first {
+		b.WriteByte(',')
+	}
+	name, _ := json.Marshal(key)
+	b.Write(name)
+	b.WriteByte(':')
+	b.Write(encoded)
+}
+
+// splitCaller returns the caller, its function and the remaining fields. Entries
+// are shared by every output, so the entry data is left untouched.
+func splitCaller(data logrus.Fields) (string, string, logrus.Fields) {
+	caller, function := "unknown", ""
+	fields := make(logrus.Fields, len(data))
+	for key, value := range data {
+		switch key {
+		case callerKey:
+			caller = fmt.Sprint(value)
+		case funcKey:
+			function = fmt.Sprint(value)
+		default:
+			fields[key] = value
+		}
+	}
+	return caller, function, fields
+}
+
+// redactEntry applies the redaction rules to the message and, in place, to the
+// copied fields. Fields named after paths are redacted as a whole on a path match.
+func redactEntry(message string, fields logrus.Fields) string {
+	r := redactor.Load()
+	if r == nil {
+		return message
+	}
+
+	for key, value := range fields {
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case error:
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		default:
+			continue
+		}
+
+		if pathFields[key] {
+			fields[key] = r.Path(s)
+		} else {
+			fields[key] = r.Text(s)
+		}
+	}
+	return r.Text(message)
+}
+
+func sortedKeys(fields logrus.Fields) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// newFormatter returns the formatter for a log format, "text" or "json"
+func newFormatter(format string, colors bool) logrus.Formatter {
+	if strings.ToLower(format) == "json" {
+		return &JSONFormatter{}
+	}
+	return &CustomFormatter{Colors: colors}
+}
+
+// isTerminal reports whether colors should be written to w: it must be a
+// terminal, and NO_COLOR and TERM=dumb turn colors off
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// writerHook writes entries to one output with its own formatter, so the
+// console can be colored while the log file stays plain
+type writerHook struct {
+	mu        sync.Mutex
+	writer    io.Writer
+	formatter logrus.Formatter
+}
+
+func (h *writerHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *writerHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.writer.Write(line)
+	return err
+}
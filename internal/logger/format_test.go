@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/redact"
+	"github.com/sirupsen/logrus"
+)
+
+func newEntry() *logrus.Entry {
+	entry := logrus.NewEntry(logrus.New())
+	entry.Time = time.Date(2025, 6, 8, 14, 30, 0, 0, time.UTC)
+	entry.Level = logrus.WarnLevel
+	entry.Message = "Failed to deliver event"
+	entry.Data = logrus.Fields{
+		callerKey: "pipeline.go:42",
+		"sink":    "s3",
+		"path":    "/vault/note.md",
+		"error":   errors.New("access denied"),
+	}
+	return entry
+}
+
+func TestTextFormatSortsFields(t *testing.T) {
+	line, err := (&CustomFormatter{}).Format(newEntry())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "WARNING[2025-06-08 14:30:00] pipeline.go:42: Failed to deliver event error=access denied path=/vault/note.md sink=s3\n"
+	if string(line) != expected {
+		t.Errorf("Expected %q, got %q", expected, string(line))
+	}
+	if bytes.Contains(line, []byte("\x1b[")) {
+		t.Error("Expected no color codes without a terminal")
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	entry := newEntry()
+	line, err := (&JSONFormatter{}).Format(entry)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(string(line), `{"time":"2025-06-08T14:30:00Z","level":"warning","caller":"pipeline.go:42","msg":"Failed to deliver event","error":"access denied","path"`) {
+		t.Errorf("Expected standard keys first and sorted fields, got %s", line)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(line, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if decoded["sink"] != "s3" {
+		t.Errorf("Expected sink 's3', got %v", decoded["sink"])
+	}
+
+	// The entry is shared by all outputs and must not lose its caller
+	if _, exists := entry.Data[callerKey]; !exists {
+		t.Error("Expected formatting to leave the entry data untouched")
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if isTerminal(&bytes.Buffer{}) {
+		t.Error("Expected a buffer not to be a terminal")
+	}
+}
+
+func TestFormattersRedact(t *testing.T) {
+	r, err := redact.New(redact.Rules{Patterns: []string{"access denied"}, Paths: []string{"note.md"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	SetRedactor(r)
+	defer SetRedactor(nil)
+
+	entry := newEntry()
+	entry.Message = "Failed to deliver /vault/note.md"
+	entry.Data["copy"] = "note.md"
+	entry.Data["target"] = "note.md"
+	for _, formatter := range []logrus.Formatter{&CustomFormatter{}, &JSONFormatter{}} {
+		line, err := formatter.Format(entry)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if strings.Contains(string(line), "note.md") || strings.Contains(string(line), "access denied") {
+			t.Errorf("Expected path and error to be redacted, got %s", line)
+		}
+	}
+
+	if entry.Data["path"] != "/vault/note.md" {
+		t.Errorf("Expected the entry data to be left untouched, got %v", entry.Data["path"])
+	}
+}
@@ -26,45 +26,20 @@ type Config struct {
 	MaxAge        int
 	Compress      bool
 	ConsoleOutput bool
+	// Format is "text" (default) or "json"
+	Format string
+	// ComponentLevels overrides LogLevel for component loggers, e.g. {"watcher": "warn"}
+	ComponentLevels map[string]string
 }
 
-// Custom formatter that includes caller info in the main format
-type CustomFormatter struct {
-	*logrus.TextFormatter
-}
-
-// Format renders a single log entry
-func (f *CustomFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	// Get caller info from the entry data if it exists
-	caller := "unknown"
-	if callerInfo, exists := entry.Data["caller_info"]; exists {
-		caller = callerInfo.(string)
-		// Remove it from the data so it doesn't appear twice
-		delete(entry.Data, "caller_info")
-	}
-
-	// Create the custom format: LEVEL [timestamp] caller: message
-	levelText := strings.ToUpper(entry.Level.String())
-	timestamp := entry.Time.Format("2006-01-02 15:04:05")
-
-	// Build the log line
-	logLine := fmt.Sprintf("%-7s[%s] %s: %s",
-		levelText,
-		timestamp,
-		caller,
-		entry.Message,
-	)
+// Fields are structured key/value pairs attached to a log entry
+type Fields map[string]interface{}
 
-	// Add fields if any exist
-	if len(entry.Data) > 0 {
-		for key, value := range entry.Data {
-			logLine += fmt.Sprintf(" %s=%v", key, value)
-		}
-	}
-
-	logLine += "\n"
-	return []byte(logLine), nil
-}
+// loggerDir is the directory of this package, whose frames are skipped when looking up the caller
+var loggerDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
 
 // Initialize sets up the logger with configuration
 func Initialize(config Config) {
@@ -73,31 +48,20 @@ func Initialize(config Config) {
 	}
 
 	// Set log level
-	level, err := logrus.ParseLevel(strings.ToLower(config.LogLevel))
-	if err != nil {
-		level = logrus.InfoLevel
+	SetLevel(config.LogLevel)
+	if err := SetComponentLevels(config.ComponentLevels); err != nil {
+		fmt.Printf("Ignoring component log levels: %v\n", err)
 	}
-	Log.SetLevel(level)
-
-	// Use our custom formatter
-	Log.SetFormatter(&CustomFormatter{
-		TextFormatter: &logrus.TextFormatter{
-			ForceColors:            true,
-			DisableColors:          false,
-			FullTimestamp:          true,
-			DisableLevelTruncation: false,
-			TimestampFormat:        "2006-01-02 15:04:05",
-		},
-	})
 
 	// 🔧 IMPORTANT: Disable built-in caller reporting since we handle it manually
 	Log.SetReportCaller(false)
 
-	outputs := []io.Writer{}
+	// Each output gets its own formatter, so only terminals receive colors
+	hooks := make(logrus.LevelHooks)
 
 	// Set console output if enabled
 	if config.ConsoleOutput {
-		outputs = append(outputs, os.Stdout)
+		hooks.Add(&writerHook{writer: os.Stdout, formatter: newFormatter(config.Format, isTerminal(os.Stdout))})
 	}
 
 	// Set file output if a log file is specified
@@ -118,109 +82,168 @@ func Initialize(config Config) {
 			MaxAge:     config.MaxAge,
 			Compress:   config.Compress,
 		}
-		outputs = append(outputs, logRotator)
+		hooks.Add(&writerHook{writer: logRotator, formatter: newFormatter(config.Format, false)})
 	}
 
-	// Set output to both console and file if needed
-	if len(outputs) > 0 {
-		multiWriter := io.MultiWriter(outputs...)
-		Log.SetOutput(multiWriter)
+	Log.ReplaceHooks(hooks)
+	Log.SetFormatter(newFormatter(config.Format, isTerminal(os.Stderr)))
+	if len(hooks) > 0 {
+		// The hooks do the writing
+		Log.SetOutput(io.Discard)
+	} else {
+		Log.SetOutput(os.Stderr)
 	}
 }
 
-// Helper function to get caller info and create log entry
-func getLogEntryWithCaller() *logrus.Entry {
-	if Log == nil {
+// SetLevel changes the log level at runtime, falling back to info for unknown levels.
+// Components with their own level are not affected, see SetComponentLevels.
+func SetLevel(logLevel string) {
+	level, err := logrus.ParseLevel(strings.ToLower(logLevel))
+	if err != nil {
+		level = logrus.InfoLevel
+	}
+
+	levelsMu.Lock()
+	defer levelsMu.Unlock()
+	globalLevel = level
+	updateLogrusLevel()
+}
+
+// Helper function to get caller info and create log entry, or nil when the
+// component doesn't log at this level
+func getLogEntryWithCaller(component string, level logrus.Level) *logrus.Entry {
+	if Log == nil || !enabled(component, level) {
 		return nil
 	}
 
-	// Get caller info, skipping this function and the wrapper function
-	_, file, line, ok := runtime.Caller(2)
-	if ok {
-		fileName := filepath.Base(file)
-		callerInfo := fmt.Sprintf("%s:%d", fileName, line)
-		return Log.WithField("caller_info", callerInfo)
+	fields := logrus.Fields{callerKey: "unknown"}
+	if component != "" {
+		fields[componentKey] = component
+	}
+	if frame, ok := callerFrame(); ok {
+		fields[callerKey] = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+		fields[funcKey] = shortFunction(frame.Function)
 	}
 
-	return Log.WithField("caller_info", "unknown")
+	return Log.WithFields(fields)
+}
+
+// callerFrame returns the first stack frame outside the logger package, so the
+// reported caller doesn't depend on how many wrappers sit in between
+func callerFrame() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 16)
+	// Skip runtime.Callers and callerFrame itself
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !isLoggerFrame(frame) {
+			return frame, frame.File != ""
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// isLoggerFrame reports whether a frame belongs to this package's own code;
+// its tests count as callers
+func isLoggerFrame(frame runtime.Frame) bool {
+	return filepath.Dir(frame.File) == loggerDir && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+// shortFunction trims the module path from a function name, e.g.
+// "github.com/x/internal/watcher.(*Watcher).Start" becomes "watcher.(*Watcher).Start"
+func shortFunction(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
 }
 
 // 🔧 UPDATED: Convenience methods with correct caller reporting
 func Debug(args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.DebugLevel); entry != nil {
 		entry.Debug(args...)
 	}
 }
 
 func Debugf(format string, args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.DebugLevel); entry != nil {
 		entry.Debugf(format, args...)
 	}
 }
 
 func Info(args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.InfoLevel); entry != nil {
 		entry.Info(args...)
 	}
 }
 
 func Infof(format string, args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.InfoLevel); entry != nil {
 		entry.Infof(format, args...)
 	}
 }
 
 func Warn(args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.WarnLevel); entry != nil {
 		entry.Warn(args...)
 	}
 }
 
 func Warnf(format string, args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.WarnLevel); entry != nil {
 		entry.Warnf(format, args...)
 	}
 }
 
 func Error(args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.ErrorLevel); entry != nil {
 		entry.Error(args...)
 	}
 }
 
 func Errorf(format string, args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.ErrorLevel); entry != nil {
 		entry.Errorf(format, args...)
 	}
 }
 
 func Fatal(args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.FatalLevel); entry != nil {
 		entry.Fatal(args...)
 	}
 }
 
 func Fatalf(format string, args ...interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
+	if entry := getLogEntryWithCaller("", logrus.FatalLevel); entry != nil {
 		entry.Fatalf(format, args...)
 	}
 }
 
 // 🔧 BONUS: Structured logging methods that preserve the caller format
-func InfoWithFields(message string, fields map[string]interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
-		entry.WithFields(fields).Info(message)
+func InfoWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller("", logrus.InfoLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Info(message)
+	}
+}
+
+func DebugWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller("", logrus.DebugLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Debug(message)
 	}
 }
 
-func DebugWithFields(message string, fields map[string]interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
-		entry.WithFields(fields).Debug(message)
+func WarnWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller("", logrus.WarnLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Warn(message)
 	}
 }
 
-func ErrorWithFields(message string, fields map[string]interface{}) {
-	if entry := getLogEntryWithCaller(); entry != nil {
-		entry.WithFields(fields).Error(message)
+func ErrorWithFields(message string, fields Fields) {
+	if entry := getLogEntryWithCaller("", logrus.ErrorLevel); entry != nil {
+		entry.WithFields(logrus.Fields(fields)).Error(message)
 	}
 }
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// captureLogs points the logger at a buffer with the JSON format for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	previous := Log
+	t.Cleanup(func() {
+		Log = previous
+		SetLevel("info")
+	})
+
+	var buf bytes.Buffer
+	Log = logrus.New()
+	Log.SetOutput(&buf)
+	Log.SetFormatter(&JSONFormatter{})
+	SetLevel("debug")
+	return &buf
+}
+
+// here returns the file:line of the line following its call
+func here() string {
+	_, file, line, _ := runtime.Caller(1)
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line+1)
+}
+
+func TestCallerIsReportedForEveryWrapper(t *testing.T) {
+	buf := captureLogs(t)
+	watcherLog := Component("watcher")
+	fields := Fields{"path": "/vault/note.md"}
+
+	var expected []string
+	expected = append(expected, here())
+	Debug("debug")
+	expected = append(expected, here())
+	Infof("info %d", 1)
+	expected = append(expected, here())
+	Warn("warn")
+	expected = append(expected, here())
+	Errorf("error %d", 2)
+	expected = append(expected, here())
+	InfoWithFields("info", fields)
+	expected = append(expected, here())
+	DebugWithFields("debug", fields)
+	expected = append(expected, here())
+	WarnWithFields("warn", fields)
+	expected = append(expected, here())
+	ErrorWithFields("error", fields)
+	expected = append(expected, here())
+	watcherLog.Infof("component %s", "info")
+	expected = append(expected, here())
+	watcherLog.WarnWithFields("component warn", fields)
+
+	decoder := json.NewDecoder(buf)
+	for i, want := range expected {
+		var line map[string]interface{}
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatalf("Expected log line %d, got %v", i, err)
+		}
+		if line["caller"] != want {
+			t.Errorf("Expected caller %s for %q, got %v", want, line["msg"], line["caller"])
+		}
+		if line["func"] != "logger.TestCallerIsReportedForEveryWrapper" {
+			t.Errorf("Expected func logger.TestCallerIsReportedForEveryWrapper for %q, got %v", line["msg"], line["func"])
+		}
+	}
+}
+
+func TestCallerSkipsHelpersInsideLogger(t *testing.T) {
+	buf := captureLogs(t)
+
+	// Calls through several logger frames still report this line
+	want := here()
+	func() { Component("api").ErrorWithFields("nested", nil) }()
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON log line, got %v", err)
+	}
+	if line["caller"] != want {
+		t.Errorf("Expected caller %s, got %v", want, line["caller"])
+	}
+	if line["component"] != "api" {
+		t.Errorf("Expected component 'api', got %v", line["component"])
+	}
+}
@@ -0,0 +1,173 @@
+package merge
+
+import (
+	"bytes"
+	"sort"
+)
+
+// maxCells bounds the comparison table of a merge, larger files aren't merged
+const maxCells = 1 << 22
+
+// hunk replaces the base lines [start, end) with lines from one side
+type hunk struct {
+	start, end int
+	lines      [][]byte
+	local      bool
+}
+
+// Lines merges the line changes local and remote made to base. Changes to
+// separate lines are combined; it returns false when both sides changed the
+// same or adjacent lines differently, or the files are too large to compare.
+func Lines(base, local, remote []byte) ([]byte, bool) {
+	baseLines := split(base)
+	localHunks, ok := diff(baseLines, split(local), true)
+	if !ok {
+		return nil, false
+	}
+	remoteHunks, ok := diff(baseLines, split(remote), false)
+	if !ok {
+		return nil, false
+	}
+
+	hunks := append(localHunks, remoteHunks...)
+	sort.SliceStable(hunks, func(i, j int) bool { return hunks[i].start < hunks[j].start })
+
+	var merged bytes.Buffer
+	pos := 0
+	for i := 0; i < len(hunks); {
+		// Group the hunks touching the same base lines
+		lo, hi := hunks[i].start, hunks[i].end
+		j := i + 1
+		for j < len(hunks) && hunks[j].start <= hi {
+			hi = max(hi, hunks[j].end)
+			j++
+		}
+		group := hunks[i:j]
+		i = j
+
+		for _, line := range baseLines[pos:lo] {
+			merged.Write(line)
+		}
+		pos = hi
+
+		localLines, localChanged := apply(baseLines, lo, hi, group, true)
+		remoteLines, remoteChanged := apply(baseLines, lo, hi, group, false)
+		switch {
+		case !remoteChanged:
+			merged.Write(localLines)
+		case !localChanged || bytes.Equal(localLines, remoteLines):
+			merged.Write(remoteLines)
+		default:
+			return nil, false
+		}
+	}
+	for _, line := range baseLines[pos:] {
+		merged.Write(line)
+	}
+	return merged.Bytes(), true
+}
+
+// apply returns the base lines [lo, hi) with one side's hunks applied, and whether it had any
+func apply(base [][]byte, lo, hi int, hunks []hunk, local bool) ([]byte, bool) {
+	var out bytes.Buffer
+	changed := false
+	pos := lo
+	for _, h := range hunks {
+		if h.local != local {
+			continue
+		}
+		changed = true
+		for _, line := range base[pos:h.start] {
+			out.Write(line)
+		}
+		for _, line := range h.lines {
+			out.Write(line)
+		}
+		pos = h.end
+	}
+	for _, line := range base[pos:hi] {
+		out.Write(line)
+	}
+	return out.Bytes(), changed
+}
+
+// diff returns the hunks turning base into other, from their longest common subsequence of lines
+func diff(base, other [][]byte, local bool) ([]hunk, bool) {
+	// Common leading and trailing lines never change
+	prefix := 0
+	for prefix < len(base) && prefix < len(other) && bytes.Equal(base[prefix], other[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(base)-prefix && suffix < len(other)-prefix &&
+		bytes.Equal(base[len(base)-1-suffix], other[len(other)-1-suffix]) {
+		suffix++
+	}
+	a := base[prefix : len(base)-suffix]
+	b := other[prefix : len(other)-suffix]
+	if len(a) == 0 && len(b) == 0 {
+		return nil, true
+	}
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var hunks []hunk
+	var current *hunk
+	flush := func() {
+		if current != nil {
+			hunks = append(hunks, *current)
+			current = nil
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && bytes.Equal(a[i], b[j]) {
+			flush()
+			i++
+			j++
+			continue
+		}
+		if current == nil {
+			current = &hunk{start: prefix + i, end: prefix + i, local: local}
+		}
+		if j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]) {
+			current.lines = append(current.lines, b[j])
+			j++
+		} else {
+			i++
+			current.end = prefix + i
+		}
+	}
+	flush()
+	return hunks, true
+}
+
+// split cuts content into lines, each keeping its line ending
+func split(content []byte) [][]byte {
+	var lines [][]byte
+	for len(content) > 0 {
+		n := bytes.IndexByte(content, '\n') + 1
+		if n == 0 {
+			n = len(content)
+		}
+		lines = append(lines, content[:n])
+		content = content[n:]
+	}
+	return lines
+}
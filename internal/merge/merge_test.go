@@ -0,0 +1,64 @@
+package merge
+
+import "testing"
+
+const base = `# Plan
+
+- buy milk
+- call Ana
+- book flights
+`
+
+func TestLinesCombinesSeparateChanges(t *testing.T) {
+	local := `# Plan for June
+
+- buy milk
+- call Ana
+- book flights
+`
+	remote := `# Plan
+
+- buy milk
+- call Ana
+- book flights
+- pack
+`
+	merged, ok := Lines([]byte(base), []byte(local), []byte(remote))
+	if !ok {
+		t.Fatal("Expected the changes to merge")
+	}
+	expected := `# Plan for June
+
+- buy milk
+- call Ana
+- book flights
+- pack
+`
+	if string(merged) != expected {
+		t.Errorf("Expected %q, got %q", expected, merged)
+	}
+}
+
+func TestLinesConflicts(t *testing.T) {
+	tests := []struct {
+		name          string
+		local, remote string
+		ok            bool
+		expected      string
+	}{
+		{"same line changed differently", "# Plan\n\n- buy oat milk\n- call Ana\n- book flights\n", "# Plan\n\n- buy soy milk\n- call Ana\n- book flights\n", false, ""},
+		{"same change on both sides", "# Plan\n\n- buy oat milk\n- call Ana\n- book flights\n", "# Plan\n\n- buy oat milk\n- call Ana\n- book flights\n", true, "# Plan\n\n- buy oat milk\n- call Ana\n- book flights\n"},
+		{"adjacent lines changed", "# Plan\n\n- buy oat milk\n- call Ana\n- book flights\n", "# Plan\n\n- buy milk\n- call Bea\n- book flights\n", false, ""},
+		{"deleted and kept", "# Plan\n\n- call Ana\n- book flights\n", base, true, "# Plan\n\n- call Ana\n- book flights\n"},
+	}
+	for _, tt := range tests {
+		merged, ok := Lines([]byte(base), []byte(tt.local), []byte(tt.remote))
+		if ok != tt.ok {
+			t.Errorf("%s: expected ok %v, got %v", tt.name, tt.ok, ok)
+			continue
+		}
+		if ok && string(merged) != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.expected, merged)
+		}
+	}
+}
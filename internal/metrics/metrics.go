@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "obsidian_sync"
+
+var (
+	// FsnotifyEvents counts raw fsnotify events, labelled by operation
+	FsnotifyEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fsnotify_events_total",
+		Help:      "Raw file system events received from fsnotify, by operation.",
+	}, []string{"op"})
+
+	// FsnotifyErrors counts errors reported on the fsnotify error channel
+	FsnotifyErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fsnotify_errors_total",
+		Help:      "Errors reported by fsnotify.",
+	})
+
+	// Events counts debounced file events, labelled by event type
+	Events = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Debounced file events emitted by the watcher, by event type.",
+	}, []string{"type"})
+
+	// WatchedDirectories is the number of directories currently watched, labelled by vault
+	WatchedDirectories = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "watched_directories",
+		Help:      "Number of directories registered with fsnotify, by vault.",
+	}, []string{"vault"})
+
+	// QueueDepth is the number of events waiting to be delivered to sinks
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_events",
+		Help:      "Events queued for delivery to sinks.",
+	})
+
+	// DeliveryDuration observes how long each sink takes to handle an event
+	DeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_duration_seconds",
+		Help:      "Time taken to deliver an event to a sink.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"sink"})
+
+	// DeliveryFailures counts events a sink failed to deliver
+	DeliveryFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "delivery_failures_total",
+		Help:      "Events that failed to be delivered, by sink.",
+	}, []string{"sink"})
+
+	// UploadedBytes counts file content bytes written by each sink
+	UploadedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uploaded_bytes_total",
+		Help:      "Bytes of file content uploaded, by sink.",
+	}, []string{"sink"})
+
+	// Conflicts counts files changed both locally and remotely, labelled by how they were resolved
+	Conflicts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "conflicts_total",
+		Help:      "Files changed both locally and remotely since they were last synced, by resolution.",
+	}, []string{"resolution"})
+
+	// Rescans counts scans of a vault for changes the watcher missed, labelled by what triggered them
+	Rescans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rescans_total",
+		Help:      "Scans of a vault for changes without file events, by trigger.",
+	}, []string{"reason"})
+)
+
+// Handler returns the HTTP handler that serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,85 @@
+package obsidian
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ConfigDir holds the vault's settings, themes, snippets and plugins
+const ConfigDir = ".obsidian"
+
+// junkFiles are created by operating systems and file managers, never by Obsidian
+var junkFiles = map[string]bool{
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"desktop.ini": true,
+	".directory":  true,
+	"icon\r":      true,
+}
+
+var (
+	// syncConflictPattern matches the conflict copies of Syncthing
+	// ("note.sync-conflict-20250608-143000-ABCDEFG.md"), Dropbox and Nextcloud
+	// ("note (Ana's conflicted copy 2025-06-08).md"). Our own copies say "(conflict ...)".
+	syncConflictPattern = regexp.MustCompile(`(?i)\.sync-conflict-\d{8}-\d{6}|\(.*conflicted copy.*\)`)
+	// workspacePattern matches the layout state Obsidian rewrites on every click
+	workspacePattern = "workspace*.json"
+)
+
+// IsJunk reports whether a file name is an OS or editor leftover: Finder and
+// Explorer metadata, AppleDouble files ("._note.md") and Office lock files ("~$note.md").
+func IsJunk(name string) bool {
+	return junkFiles[strings.ToLower(name)] || strings.HasPrefix(name, "._") || strings.HasPrefix(name, "~$")
+}
+
+// IsSyncConflict reports whether a file name is a conflict copy made by another sync tool.
+func IsSyncConflict(name string) bool {
+	return syncConflictPattern.MatchString(name)
+}
+
+// IsSetting reports whether a path relative to the vault is a setting synced
+// with the settings bundle: a file in the config folder other than the workspace layout.
+func IsSetting(rel string) bool {
+	parts := split(rel)
+	if len(parts) < 2 || !InConfigDir(rel) || IsJunk(parts[len(parts)-1]) {
+		return false
+	}
+	if len(parts) == 2 {
+		if matched, _ := filepath.Match(workspacePattern, parts[1]); matched {
+			return false
+		}
+	}
+	return true
+}
+
+// InConfigDir reports whether a path relative to the vault is the config folder
+// or inside it. Hidden folders within, such as a plugin's .git, are not.
+func InConfigDir(rel string) bool {
+	parts := split(rel)
+	if parts[0] != ConfigDir {
+		return false
+	}
+	for _, part := range parts[1:] {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+// IsHidden reports whether a path relative to the vault is hidden or inside a
+// hidden folder, such as the config folder, the trash or .git.
+func IsHidden(rel string) bool {
+	for _, part := range split(rel) {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// split cuts a relative path into its components
+func split(rel string) []string {
+	return strings.Split(filepath.Clean(filepath.FromSlash(rel)), string(filepath.Separator))
+}
@@ -0,0 +1,35 @@
+package obsidian
+
+import "testing"
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"Finder metadata", IsJunk(".DS_Store"), true},
+		{"Explorer thumbnails", IsJunk("Thumbs.db"), true},
+		{"AppleDouble file", IsJunk("._note.md"), true},
+		{"Office lock file", IsJunk("~$report.md"), true},
+		{"note", IsJunk("note.md"), false},
+		{"Syncthing conflict", IsSyncConflict("note.sync-conflict-20250608-143000-ABCDEFG.md"), true},
+		{"Dropbox conflict", IsSyncConflict("note (Ana's conflicted copy 2025-06-08).md"), true},
+		{"our own conflict copy", IsSyncConflict("note (conflict 2025-06-08).md"), false},
+		{"app settings", IsSetting(".obsidian/app.json"), true},
+		{"plugin file", IsSetting(".obsidian/plugins/dataview/main.js"), true},
+		{"workspace layout", IsSetting(".obsidian/workspace.json"), false},
+		{"mobile workspace layout", IsSetting(".obsidian/workspace-mobile.json"), false},
+		{"plugin git folder", IsSetting(".obsidian/plugins/dataview/.git/HEAD"), false},
+		{"config folder", IsSetting(".obsidian"), false},
+		{"note outside", IsSetting("notes/app.json"), false},
+		{"trashed note", IsHidden(".trash/note.md"), true},
+		{"hidden note", IsHidden("notes/.draft.md"), true},
+		{"visible note", IsHidden("notes/idea.md"), false},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Expected %s to be %v, got %v", tt.name, tt.want, tt.got)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The option values below are shared by the configuration, which validates them,
+// and the packages that implement them, which re-export them. Keeping them here
+// lets config stay below those packages.
+
+// Watch modes select the FileWatcher of a vault, see watcher.ModeAuto
+const (
+	WatchAuto   = "auto"
+	WatchNative = "native"
+	WatchPoll   = "poll"
+)
+
+// Symlink policies decide how the symlinks in a vault are synced, see watcher.SymlinkFollow
+const (
+	SymlinkIgnore = "ignore"
+	SymlinkFollow = "follow"
+	SymlinkLink   = "link"
+)
+
+// Storage layouts of a vault in the bucket, see uploader.LayoutContent
+const (
+	LayoutPaths   = "paths"
+	LayoutContent = "content"
+)
+
+// Conflict policies of pulls, see pull.Puller.SetPolicy
+const (
+	ConflictLocalWins  = "local-wins"
+	ConflictRemoteWins = "remote-wins"
+	ConflictKeepBoth   = "keep-both"
+	ConflictMerge      = "merge"
+)
+
+// Redaction modes, see redact.ModeMask
+const (
+	RedactMask = "mask"
+	RedactHash = "hash"
+)
+
+// RedactRules configure what gets redacted and how, see redact.Rules.
+type RedactRules struct {
+	// Mode is RedactMask (default) or RedactHash
+	Mode string
+	// Patterns are regular expressions; every match is redacted
+	Patterns []string
+	// Paths are glob patterns; a matching path is redacted as a whole. A pattern
+	// matches any path component or trailing part of the path, so "clients"
+	// covers everything in a clients folder and "*acme*" any file or folder named after acme.
+	Paths []string
+	// Salt keys the hashes, so short values can't be recovered by guessing
+	Salt string
+}
+
+// PrivacyRules select the private notes, see privacy.Rules.
+type PrivacyRules struct {
+	// Frontmatter lists key=value pairs, e.g. "sync=false" or "private=true"
+	Frontmatter []string
+	// Tags lists tags without the #, e.g. "private"; nested tags such as #private/work match too
+	Tags []string
+}
+
+// HistoryPolicy decides which earlier versions are kept: the latest Versions,
+// and all versions replaced within Retention. Nothing is kept when both are zero.
+type HistoryPolicy struct {
+	Versions  int
+	Retention time.Duration
+}
+
+// ParseFrontmatterRules parses "key=value" rules into a map.
+func ParseFrontmatterRules(rules []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		key, value, ok := strings.Cut(rule, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid frontmatter rule %q, expected key=value", rule)
+		}
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,304 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// log is the pipeline component logger
+var log = logger.Component("pipeline")
+
+// queueSize is the number of events that can be pending before Enqueue blocks
+const queueSize = 1024
+
+// ErrNotRunning is returned by Flush before the pipeline is started
+var ErrNotRunning = errors.New("pipeline is not running")
+
+// Sink is a destination for file events, such as the S3 uploader.
+type Sink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	// Send delivers a single event
+	Send(ctx context.Context, event models.FileEvent) error
+}
+
+// Committer is a sink that batches what it sends, such as the manifest of the
+// content layout. Commit is called whenever the queue is drained.
+type Committer interface {
+	Commit(ctx context.Context) error
+}
+
+// Pipeline queues file events from the watcher and delivers them to every sink.
+// Delivery can be paused at runtime; events received while paused are held,
+// keeping only the latest event per file, until delivery is resumed or flushed.
+type Pipeline struct {
+	sinks []Sink
+	queue chan models.FileEvent
+	// started is set by Start; stopped, set by Stop under stopMu, keeps Enqueue off the closed queue
+	started atomic.Bool
+	stopMu  sync.RWMutex
+	stopped bool
+
+	// control carries requests that must run on the delivery goroutine
+	control chan func()
+	paused  atomic.Bool
+	// held and heldIndex are only touched by the delivery goroutine
+	held      []models.FileEvent
+	heldIndex map[string]int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+}
+
+func New(sinks ...Sink) *Pipeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pipeline{
+		sinks:     sinks,
+		queue:     make(chan models.FileEvent, queueSize),
+		control:   make(chan func()),
+		heldIndex: make(map[string]int),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// AddSink adds a sink, before the pipeline is started.
+func (p *Pipeline) AddSink(sink Sink) {
+	p.sinks = append(p.sinks, sink)
+}
+
+// Start launches the delivery goroutine.
+func (p *Pipeline) Start() {
+	p.started.Store(true)
+	p.wg.Add(1)
+	go p.run()
+}
+
+// Stop delivers the events still in the queue and waits for delivery to finish.
+// Events held while paused are not delivered.
+func (p *Pipeline) Stop() {
+	p.stopMu.Lock()
+	if p.stopped {
+		p.stopMu.Unlock()
+		return
+	}
+	p.stopped = true
+	close(p.queue)
+	p.stopMu.Unlock()
+
+	p.wg.Wait()
+	p.cancel()
+}
+
+// Enqueue adds an event to the delivery queue. It has the signature of a
+// watcher.EventHandler so it can be registered directly on the watcher.
+// Events arriving after Stop, e.g. from a resync during shutdown, are dropped.
+func (p *Pipeline) Enqueue(event models.FileEvent) {
+	p.stopMu.RLock()
+	defer p.stopMu.RUnlock()
+	if p.stopped {
+		log.WarnWithFields("⚠️ Dropping event, the pipeline is stopped", logger.Fields{"type": event.EventType, "path": event.FilePath})
+		return
+	}
+	metrics.QueueDepth.Inc()
+	p.queue <- event
+}
+
+// Pause stops delivery to sinks. Incoming events are held until Resume or Flush.
+func (p *Pipeline) Pause() {
+	if !p.paused.Swap(true) {
+		log.Info("⏸️  Sync paused")
+	}
+}
+
+// Resume restarts delivery and sends every event held while paused.
+func (p *Pipeline) Resume() {
+	if p.paused.Swap(false) {
+		log.Info("▶️  Sync resumed")
+	}
+	if !p.started.Load() {
+		// Events are only held once delivery has started
+		return
+	}
+	// Stop cancels the context, so a resume during shutdown doesn't wait forever
+	p.do(p.ctx, p.releaseHeld)
+}
+
+// Paused reports whether delivery is currently paused.
+func (p *Pipeline) Paused() bool {
+	return p.paused.Load()
+}
+
+// Flush delivers every queued and held event, even while paused, and returns
+// once they have been handed to the sinks and committed, or the context is done.
+func (p *Pipeline) Flush(ctx context.Context) error {
+	if !p.started.Load() {
+		return ErrNotRunning
+	}
+	return p.do(ctx, func() {
+		p.drainQueue()
+		p.releaseHeld()
+	})
+}
+
+// do runs fn on the delivery goroutine and waits for it to complete
+func (p *Pipeline) do(ctx context.Context, fn func()) error {
+	finished := make(chan struct{})
+	request := func() {
+		fn()
+		close(finished)
+	}
+
+	select {
+	case p.control <- request:
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Pipeline) run() {
+	defer p.wg.Done()
+	defer close(p.done)
+
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				if len(p.held) > 0 {
+					log.Warnf("⚠️ Stopping with %d paused events not delivered", len(p.held))
+				}
+				p.commit()
+				return
+			}
+			p.handle(event)
+			if len(p.queue) == 0 {
+				p.commit()
+			}
+		case request := <-p.control:
+			request()
+		}
+	}
+}
+
+// handle delivers an event, or holds it if delivery is paused
+func (p *Pipeline) handle(event models.FileEvent) {
+	if !p.paused.Load() {
+		p.deliver(event)
+		return
+	}
+
+	if i, exists := p.heldIndex[event.FilePath]; exists {
+		// A newer event supersedes the held one for the same file
+		p.held[i] = event
+		metrics.QueueDepth.Dec()
+		return
+	}
+	p.heldIndex[event.FilePath] = len(p.held)
+	p.held = append(p.held, event)
+}
+
+// drainQueue delivers everything currently waiting in the queue without blocking
+func (p *Pipeline) drainQueue() {
+	for {
+		select {
+		case event, ok := <-p.queue:
+			if !ok {
+				return
+			}
+			p.deliver(event)
+		default:
+			return
+		}
+	}
+}
+
+func (p *Pipeline) releaseHeld() {
+	held := p.held
+	p.held = nil
+	p.heldIndex = make(map[string]int)
+
+	for _, event := range held {
+		p.deliver(event)
+	}
+	p.commit()
+}
+
+// commit has the sinks batching their deliveries commit them
+func (p *Pipeline) commit() {
+	for _, sink := range p.sinks {
+		committer, ok := sink.(Committer)
+		if !ok {
+			continue
+		}
+		if err := committer.Commit(p.ctx); err != nil {
+			metrics.DeliveryFailures.WithLabelValues(sink.Name()).Inc()
+			log.ErrorWithFields("⚠️ Failed to commit delivered events", logger.Fields{"sink": sink.Name(), "error": err})
+		}
+	}
+}
+
+// deliver sends the event to each sink in turn, recording latency and failures per sink
+func (p *Pipeline) deliver(event models.FileEvent) {
+	defer metrics.QueueDepth.Dec()
+
+	for _, sink := range p.sinks {
+		start := time.Now()
+		err := p.send(sink, event)
+		metrics.DeliveryDuration.WithLabelValues(sink.Name()).Observe(time.Since(start).Seconds())
+
+		fields := eventFields(event, sink)
+		if err != nil {
+			metrics.DeliveryFailures.WithLabelValues(sink.Name()).Inc()
+			fields["error"] = err
+			log.ErrorWithFields("⚠️ Failed to deliver event", fields)
+			continue
+		}
+
+		fields["duration"] = time.Since(start).String()
+		log.DebugWithFields("📤 Delivered event", fields)
+	}
+}
+
+// eventFields returns the log fields describing the delivery of an event to a sink
+func eventFields(event models.FileEvent, sink Sink) logger.Fields {
+	fields := logger.Fields{
+		"type": event.EventType,
+		"path": event.FilePath,
+		"sink": sink.Name(),
+	}
+	if event.VaultID != "" {
+		fields["vault"] = event.VaultID
+	}
+	return fields
+}
+
+// send calls the sink, turning a panic into a delivery failure so the other sinks
+// and the pipeline keep running
+func (p *Pipeline) send(sink Sink, event models.FileEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sink panicked: %v", r)
+		}
+	}()
+	return sink.Send(p.ctx, event)
+}
@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeSink struct {
+	name string
+	err  error
+
+	mu     sync.Mutex
+	events []models.FileEvent
+}
+
+func (s *fakeSink) Name() string { return s.name }
+
+func (s *fakeSink) Send(ctx context.Context, event models.FileEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return s.err
+}
+
+// committingSink records how many of the events it was sent are committed
+type committingSink struct {
+	fakeSink
+	committed int
+}
+
+func (s *committingSink) Commit(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.committed = len(s.events)
+	return nil
+}
+
+func TestPipelineDeliversToAllSinks(t *testing.T) {
+	ok := &fakeSink{name: "ok"}
+	failing := &fakeSink{name: "failing", err: errors.New("boom")}
+
+	failuresBefore := testutil.ToFloat64(metrics.DeliveryFailures.WithLabelValues("failing"))
+
+	p := New(ok, failing)
+	p.Start()
+	p.Enqueue(models.FileEvent{EventType: models.EventCreated, FilePath: "/vault/a.md"})
+	p.Enqueue(models.FileEvent{EventType: models.EventDeleted, FilePath: "/vault/b.md"})
+	p.Stop()
+
+	if len(ok.events) != 2 {
+		t.Errorf("Expected 2 events delivered to ok sink, got %d", len(ok.events))
+	}
+	if len(failing.events) != 2 {
+		t.Errorf("Expected 2 delivery attempts to failing sink, got %d", len(failing.events))
+	}
+
+	failures := testutil.ToFloat64(metrics.DeliveryFailures.WithLabelValues("failing")) - failuresBefore
+	if failures != 2 {
+		t.Errorf("Expected 2 recorded failures, got %v", failures)
+	}
+
+	if depth := testutil.ToFloat64(metrics.QueueDepth); depth != 0 {
+		t.Errorf("Expected empty queue after stop, got %v", depth)
+	}
+}
+
+func TestPipelineHoldsEventsWhilePaused(t *testing.T) {
+	sink := &fakeSink{name: "paused"}
+
+	p := New(sink)
+	p.Start()
+	defer p.Stop()
+
+	p.Pause()
+	p.Enqueue(models.FileEvent{EventType: models.EventCreated, FilePath: "/vault/a.md"})
+	p.Enqueue(models.FileEvent{EventType: models.EventModified, FilePath: "/vault/a.md"})
+	p.Enqueue(models.FileEvent{EventType: models.EventCreated, FilePath: "/vault/b.md"})
+
+	// Once the queue is empty, a round trip through the control loop
+	// guarantees the last event has been handled
+	for len(p.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	p.do(context.Background(), func() {})
+
+	sink.mu.Lock()
+	delivered := len(sink.events)
+	sink.mu.Unlock()
+	if delivered != 0 {
+		t.Fatalf("Expected no deliveries while paused, got %d", delivered)
+	}
+
+	p.Resume()
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) != 2 {
+		t.Fatalf("Expected 2 coalesced events after resume, got %d", len(sink.events))
+	}
+	if sink.events[0].EventType != models.EventModified {
+		t.Errorf("Expected latest event for a.md to win, got %s", sink.events[0].EventType)
+	}
+}
+
+func TestPipelineDropsEventsAfterStop(t *testing.T) {
+	sink := &fakeSink{name: "ok"}
+	p := New(sink)
+	p.Start()
+	p.Stop()
+
+	// A resync or watcher flush during shutdown must not panic
+	p.Enqueue(models.FileEvent{EventType: models.EventModified, FilePath: "/vault/a.md"})
+	p.Resume()
+
+	if len(sink.events) != 0 {
+		t.Errorf("Expected no events delivered after stop, got %d", len(sink.events))
+	}
+}
+
+func TestPipelineNotStarted(t *testing.T) {
+	p := New(&fakeSink{name: "ok"})
+
+	resumed := make(chan struct{})
+	go func() {
+		p.Pause()
+		p.Resume()
+		close(resumed)
+	}()
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected resume to return before the pipeline is started")
+	}
+
+	if err := p.Flush(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+}
+
+func TestPipelineCommitsOnceDrained(t *testing.T) {
+	sink := &committingSink{fakeSink: fakeSink{name: "batching"}}
+	p := New(sink)
+	p.Start()
+	defer p.Stop()
+
+	p.Pause()
+	p.Enqueue(models.FileEvent{EventType: models.EventCreated, FilePath: "/vault/a.md"})
+	p.Enqueue(models.FileEvent{EventType: models.EventCreated, FilePath: "/vault/b.md"})
+	if err := p.Flush(context.Background()); err != nil {
+		t.Fatalf("Expected no error flushing, got %v", err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.events) != 2 {
+		t.Errorf("Expected 2 events delivered, got %d", len(sink.events))
+	}
+	if sink.committed != 2 {
+		t.Errorf("Expected the flushed events to be committed, got %d committed", sink.committed)
+	}
+}
@@ -0,0 +1,201 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/options"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// log is the pipeline component logger, since the filter decides what gets synced
+var log = logger.Component("pipeline")
+
+// inlineTag matches #tags in note bodies; headings have a space after the #
+var inlineTag = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]+)`)
+
+// Rules decide which notes are private.
+type Rules = options.PrivacyRules
+
+// Filter keeps private notes out of the pipeline. Events for private notes are
+// dropped, or turned into deletes when the note may have been synced before,
+// so toggling a note to private removes it from the sinks on its next change.
+type Filter struct {
+	mu          sync.RWMutex
+	frontmatter map[string]string
+	tags        []string
+
+	// stateMu guards private, the last decision per path; paths not in the map
+	// are unknown, e.g. after a restart
+	stateMu sync.Mutex
+	private map[string]bool
+}
+
+func New(rules Rules) (*Filter, error) {
+	f := &Filter{private: make(map[string]bool)}
+	if err := f.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetRules replaces the rules, e.g. after a configuration reload.
+func (f *Filter) SetRules(rules Rules) error {
+	frontmatter, err := options.ParseFrontmatterRules(rules.Frontmatter)
+	if err != nil {
+		return err
+	}
+
+	tags := make([]string, 0, len(rules.Tags))
+	for _, tag := range rules.Tags {
+		tags = append(tags, strings.ToLower(strings.TrimPrefix(tag, "#")))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frontmatter = frontmatter
+	f.tags = tags
+	return nil
+}
+
+// Apply returns the event to deliver, and false when the event must be dropped.
+// Events for private notes become deletes unless the note is known not to have
+// been synced; an unknown note is deleted to be safe.
+func (f *Filter) Apply(event models.FileEvent) (models.FileEvent, bool) {
+	// Only notes have frontmatter and tags
+	if !strings.EqualFold(filepath.Ext(event.FilePath), ".md") {
+		return event, true
+	}
+
+	if event.EventType == models.EventDeleted {
+		f.stateMu.Lock()
+		delete(f.private, event.FilePath)
+		f.stateMu.Unlock()
+		return event, true
+	}
+
+	private, err := f.isPrivate(event.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Gone before we got to it, its delete event will follow
+		return event, true
+	}
+	if err != nil {
+		// Without knowing, keep the note local
+		log.WarnWithFields("⚠️ Failed to check note privacy, not syncing it", logger.Fields{"path": event.FilePath, "error": err})
+		private = true
+	}
+
+	f.stateMu.Lock()
+	wasPrivate, known := f.private[event.FilePath]
+	f.private[event.FilePath] = private
+	f.stateMu.Unlock()
+
+	if !private {
+		return event, true
+	}
+
+	if known && wasPrivate {
+		log.DebugWithFields("🔒 Skipping private note", logger.Fields{"path": event.FilePath})
+		return event, false
+	}
+
+	log.InfoWithFields("🔒 Note is private, removing it from sync", logger.Fields{"path": event.FilePath})
+	return models.FileEvent{
+		EventType: models.EventDeleted,
+		FilePath:  event.FilePath,
+		VaultPath: event.VaultPath,
+		VaultID:   event.VaultID,
+		Timestamp: event.Timestamp,
+	}, true
+}
+
+// isPrivate reads a note and checks its frontmatter and tags against the rules
+func (f *Filter) isPrivate(path string) (bool, error) {
+	f.mu.RLock()
+	frontmatterRules, tagRules := f.frontmatter, f.tags
+	f.mu.RUnlock()
+
+	if len(frontmatterRules) == 0 && len(tagRules) == 0 {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	meta, body := splitFrontmatter(data)
+	for key, want := range frontmatterRules {
+		if value, exists := meta[key]; exists && strings.EqualFold(fmt.Sprint(value), want) {
+			return true, nil
+		}
+	}
+
+	for _, tag := range noteTags(meta, body) {
+		for _, rule := range tagRules {
+			if tag == rule || strings.HasPrefix(tag, rule+"/") {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// splitFrontmatter returns the parsed YAML frontmatter and the rest of the note.
+// Invalid frontmatter is treated as absent, like Obsidian does.
+func splitFrontmatter(data []byte) (map[string]interface{}, []byte) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !bytes.HasPrefix(data, []byte("---\n")) && !bytes.HasPrefix(data, []byte("---\r\n")) {
+		return nil, data
+	}
+
+	rest := data[bytes.IndexByte(data, '\n')+1:]
+	end := 0
+	for end < len(rest) {
+		lineEnd := bytes.IndexByte(rest[end:], '\n')
+		line := rest[end:]
+		if lineEnd >= 0 {
+			line = rest[end : end+lineEnd+1]
+		}
+		if trimmed := bytes.TrimRight(line, "\r\n"); bytes.Equal(trimmed, []byte("---")) {
+			meta := make(map[string]interface{})
+			if err := yaml.Unmarshal(rest[:end], &meta); err != nil {
+				return nil, data
+			}
+			return meta, rest[end+len(line):]
+		}
+		if lineEnd < 0 {
+			break
+		}
+		end += lineEnd + 1
+	}
+	return nil, data
+}
+
+// noteTags returns the lowercase tags from the frontmatter tags property and inline #tags
+func noteTags(meta map[string]interface{}, body []byte) []string {
+	var tags []string
+	switch v := meta["tags"].(type) {
+	case string:
+		for _, tag := range strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' }) {
+			tags = append(tags, strings.ToLower(strings.TrimPrefix(tag, "#")))
+		}
+	case []interface{}:
+		for _, tag := range v {
+			tags = append(tags, strings.ToLower(strings.TrimPrefix(fmt.Sprint(tag), "#")))
+		}
+	}
+
+	for _, match := range inlineTag.FindAllSubmatch(body, -1) {
+		tags = append(tags, strings.ToLower(string(match[1])))
+	}
+	return tags
+}
@@ -0,0 +1,116 @@
+package privacy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func newFilter(t *testing.T) *Filter {
+	t.Helper()
+	f, err := New(Rules{Frontmatter: []string{"sync=false", "private=true"}, Tags: []string{"#private"}})
+	if err != nil {
+		t.Fatalf("Failed to create filter: %v", err)
+	}
+	return f
+}
+
+func writeNote(t *testing.T, path, content string) models.FileEvent {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return models.FileEvent{EventType: models.EventModified, FilePath: path}
+}
+
+func TestPrivateNotes(t *testing.T) {
+	dir := t.TempDir()
+	f := newFilter(t)
+
+	tests := []struct {
+		name    string
+		content string
+		private bool
+	}{
+		{"plain note", "# Shopping\n\n- milk", false},
+		{"sync false", "---\nsync: false\n---\n# Diary", true},
+		{"private true", "---\ntitle: Diary\nprivate: true\n---\n", true},
+		{"private false", "---\nprivate: false\n---\n", false},
+		{"frontmatter tag", "---\ntags: [work, private]\n---\n", true},
+		{"frontmatter tag string", "---\ntags: \"#private\"\n---\n", true},
+		{"inline tag", "Meeting notes #private", true},
+		{"nested tag", "#private/health", true},
+		{"similar tag", "#privateer", false},
+		{"heading", "# private", false},
+		{"invalid frontmatter", "---\nprivate: [true\n---\n", false},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, tt.name+".md")
+		writeNote(t, path, tt.content)
+
+		private, err := f.isPrivate(path)
+		if err != nil {
+			t.Fatalf("Test %d (%s): unexpected error: %v", i, tt.name, err)
+		}
+		if private != tt.private {
+			t.Errorf("Test %d (%s): expected private %v, got %v", i, tt.name, tt.private, private)
+		}
+	}
+}
+
+func TestTogglingPrivacy(t *testing.T) {
+	note := filepath.Join(t.TempDir(), "note.md")
+	f := newFilter(t)
+
+	// A public note is synced as is
+	event, ok := f.Apply(writeNote(t, note, "# Note"))
+	if !ok || event.EventType != models.EventModified {
+		t.Fatalf("Expected the modification to pass, got %v (%v)", event.EventType, ok)
+	}
+
+	// Marking it private deletes the synced copy
+	event, ok = f.Apply(writeNote(t, note, "---\nsync: false\n---\n# Note"))
+	if !ok || event.EventType != models.EventDeleted {
+		t.Fatalf("Expected a delete, got %v (%v)", event.EventType, ok)
+	}
+
+	// Further changes stay local
+	if _, ok := f.Apply(writeNote(t, note, "---\nsync: false\n---\n# Note, edited")); ok {
+		t.Error("Expected changes to a private note to be dropped")
+	}
+
+	// Making it public again syncs it
+	event, ok = f.Apply(writeNote(t, note, "# Note, edited"))
+	if !ok || event.EventType != models.EventModified {
+		t.Errorf("Expected the modification to pass, got %v (%v)", event.EventType, ok)
+	}
+}
+
+func TestUnknownPrivateNoteIsDeleted(t *testing.T) {
+	dir := t.TempDir()
+	f := newFilter(t)
+
+	// After a restart the filter can't tell whether the note was synced before
+	event, ok := f.Apply(writeNote(t, filepath.Join(dir, "diary.md"), "#private"))
+	if !ok || event.EventType != models.EventDeleted {
+		t.Errorf("Expected a delete, got %v (%v)", event.EventType, ok)
+	}
+}
+
+func TestOtherFilesPass(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	f := newFilter(t)
+
+	if _, ok := f.Apply(writeNote(t, path, "#private")); !ok {
+		t.Error("Expected files other than notes to pass")
+	}
+}
+
+func TestInvalidFrontmatterRule(t *testing.T) {
+	if _, err := New(Rules{Frontmatter: []string{"private"}}); err == nil {
+		t.Error("Expected an error for a rule without a value")
+	}
+}
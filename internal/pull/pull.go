@@ -0,0 +1,545 @@
+package pull
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/merge"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/internal/options"
+	"github.com/aarangop/obsidian-sync/internal/state"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// log is the pipeline component logger, pulls being the other direction of sync
+var log = logger.Component("pipeline")
+
+// Conflict policies, see Puller.SetPolicy
+const (
+	PolicyLocalWins  = options.ConflictLocalWins
+	PolicyRemoteWins = options.ConflictRemoteWins
+	PolicyKeepBoth   = options.ConflictKeepBoth
+	PolicyMerge      = options.ConflictMerge
+)
+
+// maxConflicts is how many recent conflicts are kept for the status
+const maxConflicts = 100
+
+// ApplyHandler is called before the puller writes or deletes a file, with the
+// new content hash or an empty hash for a deletion, so the watcher's echo can be dropped
+type ApplyHandler func(path, hash string)
+
+// ResyncHandler is called with the local changes a conflict resolution needs
+// uploaded: the winning local version, a merge or a conflict copy
+type ResyncHandler func(event models.FileEvent)
+
+// Result counts what a pull changed in the vault.
+type Result struct {
+	Updated   int
+	Deleted   int
+	Conflicts int
+}
+
+// Puller brings changes other writers made to a vault's manifest into the
+// vault. The sync record tells which side changed a file: a file that changed
+// remotely but not locally is updated, one that changed on both sides is a
+// conflict resolved by the conflict policy.
+type Puller struct {
+	content   *uploader.ContentUploader
+	store     uploader.ObjectStore
+	record    *state.Record
+	vaultPath string
+	prefix    string
+	vaultID   string
+
+	onApply  ApplyHandler
+	onResync ResyncHandler
+	now      func() time.Time
+	// filter selects the paths pulled into the vault, nil pulls every path;
+	// skipped remembers the paths already reported as not pulled
+	filter  func(path string) bool
+	skipped map[string]bool
+
+	// mu serializes pulls from the loop and from callers of Pull
+	mu sync.Mutex
+	// resyncs are the local changes made by the running pull, handed to onResync once it is done
+	resyncs []models.FileEvent
+
+	// conflictsMu guards the policy and the recent conflicts
+	conflictsMu sync.Mutex
+	policy      string
+	conflicts   []models.Conflict
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func New(content *uploader.ContentUploader, store uploader.ObjectStore, record *state.Record, vaultPath, prefix string) *Puller {
+	return &Puller{
+		content:   content,
+		store:     store,
+		record:    record,
+		vaultPath: vaultPath,
+		prefix:    prefix,
+		policy:    PolicyKeepBoth,
+		now:       time.Now,
+		skipped:   make(map[string]bool),
+		stop:      make(chan struct{}),
+	}
+}
+
+// SetPolicy changes how conflicts are resolved: PolicyLocalWins, PolicyRemoteWins,
+// PolicyKeepBoth or PolicyMerge.
+func (p *Puller) SetPolicy(policy string) {
+	p.conflictsMu.Lock()
+	defer p.conflictsMu.Unlock()
+	p.policy = policy
+}
+
+// Conflicts returns the most recent conflicts, oldest first.
+func (p *Puller) Conflicts() []models.Conflict {
+	p.conflictsMu.Lock()
+	defer p.conflictsMu.Unlock()
+	return append([]models.Conflict(nil), p.conflicts...)
+}
+
+// SetVaultID sets the vault ID added to log entries.
+func (p *Puller) SetVaultID(id string) {
+	p.vaultID = id
+}
+
+// SetFilter restricts pulls to the paths for which filter returns true, so a
+// writer to the bucket can't place files in the vault that it doesn't sync, such
+// as plugins, hidden or ignored files. Call it before Start.
+func (p *Puller) SetFilter(filter func(path string) bool) {
+	p.filter = filter
+}
+
+// OnApply registers the handler called before each change to the vault.
+func (p *Puller) OnApply(handler ApplyHandler) {
+	p.onApply = handler
+}
+
+// OnResync registers the handler uploading the local changes made by resolving conflicts.
+func (p *Puller) OnResync(handler ResyncHandler) {
+	p.onResync = handler
+}
+
+// Start pulls every interval in the background until Stop.
+func (p *Puller) Start(interval time.Duration) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.Pull(context.Background()); err != nil {
+				log.WarnWithFields("⚠️ Pull failed", p.fields(logger.Fields{"error": err}))
+			}
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the pull loop and waits for a running pull to finish.
+func (p *Puller) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// pulls reports whether path passes the filter, reporting the first time it doesn't.
+// It is called with mu held.
+func (p *Puller) pulls(path string) bool {
+	if p.filter == nil || p.filter(path) {
+		return true
+	}
+	if !p.skipped[path] {
+		p.skipped[path] = true
+		log.WarnWithFields("⚠️ Not pulling a file the vault doesn't sync", p.fields(logger.Fields{"path": path}))
+	}
+	return false
+}
+
+// Pull applies the remote changes once. Files that fail don't stop the pull and are reported together.
+// Uploads wait for the pull, so a local change can't be taken for a remote one while it is decided.
+func (p *Puller) Pull(ctx context.Context) (Result, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var result Result
+	var errs []error
+	err := p.content.WithManifest(ctx, func(remote map[string]uploader.ManifestEntry) error {
+		// Files synced while pulling, such as conflict copies, aren't remote deletions
+		recorded := p.record.Paths()
+
+		for path, entry := range remote {
+			if entry.Link {
+				// Symlinks are restored, not pulled
+				continue
+			}
+			if !p.pulls(path) {
+				continue
+			}
+			if err := p.pullFile(ctx, path, entry.Hash, &result); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			}
+		}
+
+		// Recorded files missing from the manifest were deleted remotely
+		for _, path := range recorded {
+			if _, exists := remote[path]; exists || !p.pulls(path) {
+				continue
+			}
+			if err := p.pullFile(ctx, path, "", &result); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			}
+		}
+		return nil
+	})
+
+	// The local changes of resolutions are uploaded once uploads can go ahead again
+	resyncs := p.resyncs
+	p.resyncs = nil
+	if p.onResync != nil {
+		for _, event := range resyncs {
+			p.onResync(event)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if result.Updated > 0 || result.Deleted > 0 || result.Conflicts > 0 {
+		log.InfoWithFields("⬇️  Pulled remote changes", p.fields(logger.Fields{"updated": result.Updated, "deleted": result.Deleted, "conflicts": result.Conflicts}))
+	}
+	return result, errors.Join(errs...)
+}
+
+// pullFile brings one file to the remote hash, an empty hash meaning it was deleted remotely
+func (p *Puller) pullFile(ctx context.Context, path, remote string, result *Result) error {
+	target, err := p.localPath(path)
+	if err != nil {
+		return err
+	}
+
+	synced, _ := p.record.Get(path)
+	if remote == synced {
+		// Nothing changed remotely since the last sync
+		return nil
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		// Writing would replace the link with a file
+		log.DebugWithFields("⏭️ Skipping symlink", p.fields(logger.Fields{"path": target}))
+		return nil
+	}
+
+	local, err := hashFile(target)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case local == remote:
+		// Both sides already agree
+	case local != synced:
+		result.Conflicts++
+		return p.resolve(ctx, path, target, local, remote)
+	case remote == "":
+		if err := p.remove(target); err != nil {
+			return err
+		}
+		log.InfoWithFields("🗑️  Deleted file removed remotely", p.fields(logger.Fields{"path": target}))
+		result.Deleted++
+	default:
+		if err := p.download(ctx, target, remote); err != nil {
+			return err
+		}
+		log.InfoWithFields("⬇️  Updated file changed remotely", p.fields(logger.Fields{"path": target}))
+		result.Updated++
+	}
+
+	return p.recordSynced(path, remote)
+}
+
+// resolve settles a file that changed both locally and remotely since it was last synced
+func (p *Puller) resolve(ctx context.Context, path, target, local, remote string) error {
+	p.conflictsMu.Lock()
+	policy := p.policy
+	p.conflictsMu.Unlock()
+
+	conflict := models.Conflict{Path: path, VaultID: p.vaultID, Policy: policy, Resolution: policy, Time: p.now().UTC()}
+
+	// With keep-both and merge, a deleted side loses against changed content
+	if policy != PolicyRemoteWins && policy != PolicyLocalWins {
+		switch {
+		case local == "":
+			conflict.Resolution = PolicyRemoteWins
+		case remote == "":
+			conflict.Resolution = PolicyLocalWins
+		}
+	}
+	if conflict.Resolution == PolicyMerge {
+		merged, err := p.merge(ctx, path, target, local, remote)
+		if err != nil {
+			return err
+		}
+		if !merged {
+			conflict.Resolution = PolicyKeepBoth
+		}
+	}
+
+	switch conflict.Resolution {
+	case PolicyLocalWins:
+		// The remote version counts as synced, so the local one replaces it
+		if err := p.recordSynced(path, remote); err != nil {
+			return err
+		}
+		p.resync(target, local)
+	case PolicyRemoteWins:
+		if remote == "" {
+			if err := p.remove(target); err != nil {
+				return err
+			}
+		} else if err := p.download(ctx, target, remote); err != nil {
+			return err
+		}
+		if err := p.recordSynced(path, remote); err != nil {
+			return err
+		}
+	case PolicyKeepBoth:
+		copyPath, err := p.keepCopy(target)
+		if err != nil {
+			return err
+		}
+		conflict.Copy = copyPath
+		if err := p.download(ctx, target, remote); err != nil {
+			return err
+		}
+		if err := p.recordSynced(path, remote); err != nil {
+			return err
+		}
+	}
+
+	p.addConflict(conflict)
+	return nil
+}
+
+// merge combines the local and remote changes to a note since the version last
+// synced, returning false when they can't be merged
+func (p *Puller) merge(ctx context.Context, path, target, local, remote string) (bool, error) {
+	synced, _ := p.record.Get(path)
+	if synced == "" || !strings.HasSuffix(strings.ToLower(path), ".md") {
+		return false, nil
+	}
+	base, err := p.blob(ctx, synced)
+	if errors.Is(err, uploader.ErrNotFound) {
+		// The history no longer holds the common version
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	remoteBody, err := p.blob(ctx, remote)
+	if err != nil {
+		return false, err
+	}
+	localBody, err := os.ReadFile(target)
+	if err != nil {
+		return false, err
+	}
+
+	merged, ok := merge.Lines(base, localBody, remoteBody)
+	if !ok {
+		return false, nil
+	}
+	hash := hashBytes(merged)
+	if err := p.write(target, merged, hash); err != nil {
+		return false, err
+	}
+	if err := p.recordSynced(path, remote); err != nil {
+		return false, err
+	}
+	p.resync(target, hash)
+	return true, nil
+}
+
+// keepCopy saves the local version of a conflicting file next to it, e.g.
+// "note (conflict 2026-10-16).md", and returns the copy's path in the vault
+func (p *Puller) keepCopy(target string) (string, error) {
+	body, err := os.ReadFile(target)
+	if err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(target)
+	stem := strings.TrimSuffix(target, ext) + " (conflict " + p.now().Format("2006-01-02")
+	copyPath := stem + ")" + ext
+	for n := 2; ; n++ {
+		if _, err := os.Stat(copyPath); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		copyPath = fmt.Sprintf("%s %d)%s", stem, n, ext)
+	}
+
+	hash := hashBytes(body)
+	if err := p.write(copyPath, body, hash); err != nil {
+		return "", err
+	}
+	p.resync(copyPath, hash)
+
+	rel, err := filepath.Rel(p.vaultPath, copyPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+func (p *Puller) addConflict(conflict models.Conflict) {
+	p.conflictsMu.Lock()
+	p.conflicts = append(p.conflicts, conflict)
+	if len(p.conflicts) > maxConflicts {
+		p.conflicts = p.conflicts[len(p.conflicts)-maxConflicts:]
+	}
+	p.conflictsMu.Unlock()
+
+	metrics.Conflicts.WithLabelValues(conflict.Resolution).Inc()
+	log.WarnWithFields("⚔️  Resolved conflicting changes", p.fields(logger.Fields{"path": conflict.Path, "policy": conflict.Policy, "resolution": conflict.Resolution, "copy": conflict.Copy}))
+}
+
+// resync queues a local change made by a resolution for upload after the pull
+func (p *Puller) resync(target, hash string) {
+	event := models.FileEvent{
+		EventType: models.EventModified,
+		FilePath:  target,
+		VaultPath: p.vaultPath,
+		VaultID:   p.vaultID,
+		Timestamp: p.now().UTC(),
+		Checksum:  hash,
+	}
+	if hash == "" {
+		event.EventType = models.EventDeleted
+	} else if info, err := os.Stat(target); err == nil {
+		event.FileSize = info.Size()
+	}
+	p.resyncs = append(p.resyncs, event)
+}
+
+// recordSynced updates the sync record, an empty hash meaning the file is gone on both sides
+func (p *Puller) recordSynced(path, hash string) error {
+	if hash == "" {
+		return p.record.Delete(path)
+	}
+	return p.record.Set(path, hash)
+}
+
+// remove deletes a file removed remotely
+func (p *Puller) remove(target string) error {
+	p.applied(target, "")
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// blob returns the content with the given hash, checked against the hash
+func (p *Puller) blob(ctx context.Context, hash string) ([]byte, error) {
+	body, _, _, err := p.store.Get(ctx, uploader.BlobKey(p.prefix, hash))
+	if err != nil {
+		return nil, err
+	}
+	if hashBytes(body) != hash {
+		return nil, fmt.Errorf("downloaded content doesn't match hash %s", hash)
+	}
+	return body, nil
+}
+
+// download writes the blob with the given hash to target
+func (p *Puller) download(ctx context.Context, target, hash string) error {
+	body, err := p.blob(ctx, hash)
+	if err != nil {
+		return err
+	}
+	return p.write(target, body, hash)
+}
+
+// write replaces target through a hidden temporary file, which the watcher
+// ignores, so the watcher only sees the finished file
+func (p *Puller) write(target string, body []byte, hash string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".pull-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	p.applied(target, hash)
+	return os.Rename(tmp.Name(), target)
+}
+
+func (p *Puller) applied(target, hash string) {
+	if p.onApply != nil {
+		p.onApply(target, hash)
+	}
+}
+
+// localPath converts a manifest path into a path inside the vault
+func (p *Puller) localPath(path string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("not a path inside the vault")
+	}
+	return filepath.Join(p.vaultPath, rel), nil
+}
+
+func (p *Puller) fields(extra logger.Fields) logger.Fields {
+	if p.vaultID != "" {
+		extra["vault"] = p.vaultID
+	}
+	return extra
+}
+
+// hashFile returns the SHA-256 of a file's content, or an empty hash if it doesn't exist
+func hashFile(path string) (string, error) {
+	body, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return hashBytes(body), nil
+}
+
+func hashBytes(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,279 @@
+package pull
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/state"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+	"github.com/aarangop/obsidian-sync/internal/watcher"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// device is one copy of a vault syncing with the content layout through a shared store
+type device struct {
+	t       *testing.T
+	dir     string
+	sink    *uploader.ContentUploader
+	puller  *Puller
+	applied map[string]string
+}
+
+func newDevice(t *testing.T, store uploader.ObjectStore) *device {
+	d := &device{t: t, dir: t.TempDir(), applied: make(map[string]string)}
+	record, err := state.Open(filepath.Join(t.TempDir(), "work.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.sink = uploader.NewContent(store, d.dir, "work/")
+	d.sink.SetRecord(record)
+	d.puller = New(d.sink, store, record, d.dir, "work/")
+	d.puller.OnApply(func(path, hash string) { d.applied[path] = hash })
+	// Uploads straight away, where the daemon goes through the pipeline
+	d.puller.OnResync(func(event models.FileEvent) {
+		if err := d.sink.Send(context.Background(), event); err != nil {
+			t.Errorf("Expected no error uploading %s, got %v", event.FilePath, err)
+		}
+		if err := d.sink.Commit(context.Background()); err != nil {
+			t.Errorf("Expected no error committing %s, got %v", event.FilePath, err)
+		}
+	})
+	d.puller.now = func() time.Time { return time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local) }
+	return d
+}
+
+func (d *device) write(name, content string) {
+	d.t.Helper()
+	path := filepath.Join(d.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		d.t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		d.t.Fatal(err)
+	}
+	d.send(models.EventModified, name)
+}
+
+func (d *device) remove(name string) {
+	d.t.Helper()
+	if err := os.Remove(filepath.Join(d.dir, name)); err != nil {
+		d.t.Fatal(err)
+	}
+	d.send(models.EventDeleted, name)
+}
+
+func (d *device) send(eventType models.EventType, name string) {
+	d.t.Helper()
+	event := models.FileEvent{EventType: eventType, FilePath: filepath.Join(d.dir, name)}
+	if err := d.sink.Send(context.Background(), event); err != nil {
+		d.t.Fatalf("Expected no error sending %s, got %v", name, err)
+	}
+	if err := d.sink.Commit(context.Background()); err != nil {
+		d.t.Fatalf("Expected no error committing %s, got %v", name, err)
+	}
+}
+
+func (d *device) pull() Result {
+	d.t.Helper()
+	result, err := d.puller.Pull(context.Background())
+	if err != nil {
+		d.t.Fatalf("Expected no error pulling, got %v", err)
+	}
+	return result
+}
+
+func (d *device) read(name string) string {
+	content, err := os.ReadFile(filepath.Join(d.dir, name))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+func TestPullAppliesRemoteChanges(t *testing.T) {
+	store := uploader.NewMemoryStore()
+	laptop := newDevice(t, store)
+	phone := newDevice(t, store)
+
+	laptop.write("Projects/plan.md", "# Plan")
+	laptop.write("note.md", "# Note")
+	if result := phone.pull(); result.Updated != 2 {
+		t.Errorf("Expected 2 updated files, got %+v", result)
+	}
+	if content := phone.read("Projects/plan.md"); content != "# Plan" {
+		t.Errorf("Expected '# Plan', got %q", content)
+	}
+	if _, expected := phone.applied[filepath.Join(phone.dir, "note.md")]; !expected {
+		t.Error("Expected the written note to be reported before the write")
+	}
+
+	// Nothing changed since, so a second pull does nothing
+	if result := phone.pull(); result != (Result{}) {
+		t.Errorf("Expected nothing to pull, got %+v", result)
+	}
+
+	laptop.write("note.md", "# Note, edited")
+	laptop.remove("Projects/plan.md")
+	if result := phone.pull(); result.Updated != 1 || result.Deleted != 1 {
+		t.Errorf("Expected 1 updated and 1 deleted file, got %+v", result)
+	}
+	if content := phone.read("note.md"); content != "# Note, edited" {
+		t.Errorf("Expected '# Note, edited', got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(phone.dir, "Projects", "plan.md")); err == nil {
+		t.Error("Expected the remotely deleted file to be removed")
+	}
+	if hash, exists := phone.applied[filepath.Join(phone.dir, "Projects", "plan.md")]; !exists || hash != "" {
+		t.Errorf("Expected the deletion to be reported with an empty hash, got %q", hash)
+	}
+}
+
+// conflict edits note.md on both devices after they synced it
+func conflict(t *testing.T, policy, base, laptopEdit, phoneEdit string) (*device, *device) {
+	store := uploader.NewMemoryStore()
+	laptop := newDevice(t, store)
+	phone := newDevice(t, store)
+	phone.puller.SetPolicy(policy)
+
+	laptop.write("note.md", base)
+	phone.pull()
+
+	// Edited on the phone but not synced yet, while the laptop edits too
+	if err := os.WriteFile(filepath.Join(phone.dir, "note.md"), []byte(phoneEdit), 0644); err != nil {
+		t.Fatal(err)
+	}
+	laptop.write("note.md", laptopEdit)
+	if result := phone.pull(); result.Conflicts != 1 {
+		t.Errorf("Expected 1 conflict, got %+v", result)
+	}
+	laptop.pull()
+	return laptop, phone
+}
+
+func TestPullConflictPolicies(t *testing.T) {
+	base := "# Plan\n\n- milk\n- flights\n"
+	laptopEdit := "# Plan\n\n- milk\n- flights\n- pack\n"
+	phoneEdit := "# Plan for June\n\n- milk\n- flights\n"
+	merged := "# Plan for June\n\n- milk\n- flights\n- pack\n"
+
+	tests := []struct {
+		policy     string
+		resolution string
+		content    string
+	}{
+		{PolicyLocalWins, PolicyLocalWins, phoneEdit},
+		{PolicyRemoteWins, PolicyRemoteWins, laptopEdit},
+		{PolicyKeepBoth, PolicyKeepBoth, laptopEdit},
+		{PolicyMerge, PolicyMerge, merged},
+	}
+	for _, tt := range tests {
+		laptop, phone := conflict(t, tt.policy, base, laptopEdit, phoneEdit)
+
+		conflicts := phone.puller.Conflicts()
+		if len(conflicts) != 1 || conflicts[0].Resolution != tt.resolution {
+			t.Errorf("%s: expected a %s resolution, got %+v", tt.policy, tt.resolution, conflicts)
+			continue
+		}
+		// Both devices end up with the same note
+		if content := phone.read("note.md"); content != tt.content {
+			t.Errorf("%s: expected %q on the phone, got %q", tt.policy, tt.content, content)
+		}
+		if content := laptop.read("note.md"); content != tt.content {
+			t.Errorf("%s: expected %q on the laptop, got %q", tt.policy, tt.content, content)
+		}
+	}
+}
+
+func TestPullKeepBothKeepsLocalCopy(t *testing.T) {
+	laptop, phone := conflict(t, PolicyMerge, "# Plan\n", "# Laptop plan\n", "# Phone plan\n")
+
+	// Changes to the same line can't be merged, so both versions are kept
+	conflicts := phone.puller.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Resolution != PolicyKeepBoth || conflicts[0].Copy != "note (conflict 2026-10-16).md" {
+		t.Fatalf("Expected a keep-both resolution with a copy, got %+v", conflicts)
+	}
+	for _, d := range []*device{phone, laptop} {
+		if content := d.read("note (conflict 2026-10-16).md"); content != "# Phone plan\n" {
+			t.Errorf("Expected the phone version in the conflict copy, got %q", content)
+		}
+		if content := d.read("note.md"); content != "# Laptop plan\n" {
+			t.Errorf("Expected the laptop version in the note, got %q", content)
+		}
+	}
+}
+
+func TestPullKeepsEditsToRemotelyDeletedFiles(t *testing.T) {
+	store := uploader.NewMemoryStore()
+	laptop := newDevice(t, store)
+	phone := newDevice(t, store)
+
+	laptop.write("draft.md", "# Draft")
+	phone.pull()
+	if err := os.WriteFile(filepath.Join(phone.dir, "draft.md"), []byte("# Mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	laptop.remove("draft.md")
+	phone.pull()
+	laptop.pull()
+
+	if content := phone.read("draft.md"); content != "# Mine" {
+		t.Errorf("Expected the locally edited draft to be kept, got %q", content)
+	}
+	if content := laptop.read("draft.md"); content != "# Mine" {
+		t.Errorf("Expected the edited draft to come back on the laptop, got %q", content)
+	}
+}
+
+func TestPullRejectsPathsOutsideVault(t *testing.T) {
+	store := uploader.NewMemoryStore()
+	phone := newDevice(t, store)
+
+	m, err := uploader.LoadManifest(context.Background(), store, "work/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Files["../escape.md"] = uploader.ManifestEntry{Hash: "aa"}
+	if err := m.Save(context.Background(), store, "work/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := phone.puller.Pull(context.Background()); err == nil {
+		t.Error("Expected an error for a path outside the vault")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(phone.dir), "escape.md")); err == nil {
+		t.Error("Expected nothing to be written outside the vault")
+	}
+}
+
+func TestPullSkipsFilesTheVaultDoesNotSync(t *testing.T) {
+	store := uploader.NewMemoryStore()
+	laptop, phone := newDevice(t, store), newDevice(t, store)
+	w := watcher.New(phone.dir)
+	w.SetIgnorePatterns([]string{"drafts"})
+	phone.puller.SetFilter(w.Syncs)
+
+	laptop.write("note.md", "# Note")
+	m, err := uploader.LoadManifest(context.Background(), store, "work/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := m.Files["note.md"]
+	for _, path := range []string{".obsidian/plugins/x/main.js", ".hidden.md", "run.sh", "drafts/plan.md"} {
+		m.Files[path] = entry
+	}
+	if err := m.Save(context.Background(), store, "work/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := phone.pull(); result.Updated != 1 {
+		t.Errorf("Expected only the note to be pulled, got %+v", result)
+	}
+	for _, path := range []string{".obsidian/plugins/x/main.js", ".hidden.md", "run.sh", "drafts/plan.md"} {
+		if _, err := os.Stat(filepath.Join(phone.dir, filepath.FromSlash(path))); err == nil {
+			t.Errorf("Expected %s not to be pulled", path)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aarangop/obsidian-sync/internal/options"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+const (
+	// ModeMask replaces sensitive text with a fixed placeholder
+	ModeMask = options.RedactMask
+	// ModeHash replaces sensitive text with a short hash, so equal values can still be correlated
+	ModeHash = options.RedactHash
+
+	placeholder = "[REDACTED]"
+)
+
+// pathToken finds path-like words in free text such as log messages
+var pathToken = regexp.MustCompile(`[^\s"'()\[\]{},]*/[^\s"'()\[\]{},]*`)
+
+// pathWord is a following word a path with spaces may continue with, and pathEnd
+// the extension that ends a file name
+var (
+	pathWord = regexp.MustCompile(`^ [^\s"'()\[\]{},:/][^\s"'()\[\]{},:]*`)
+	pathEnd  = regexp.MustCompile(`\.\w+$`)
+)
+
+// Rules configure what gets redacted and how.
+type Rules = options.RedactRules
+
+// Redactor removes sensitive text from log output and event metadata. A nil
+// Redactor leaves everything unchanged.
+type Redactor struct {
+	hash     bool
+	patterns []*regexp.Regexp
+	paths    []string
+	salt     []byte
+}
+
+// New compiles the rules. It returns nil when there is nothing to redact.
+func New(rules Rules) (*Redactor, error) {
+	if len(rules.Patterns) == 0 && len(rules.Paths) == 0 {
+		return nil, nil
+	}
+
+	r := &Redactor{salt: []byte(rules.Salt)}
+	switch rules.Mode {
+	case "", ModeMask:
+	case ModeHash:
+		r.hash = true
+	default:
+		return nil, fmt.Errorf("invalid redaction mode %q, use mask or hash", rules.Mode)
+	}
+
+	for _, pattern := range rules.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %v", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+
+	for _, pattern := range rules.Paths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid redaction path pattern %q", pattern)
+		}
+		r.paths = append(r.paths, filepath.FromSlash(pattern))
+	}
+
+	return r, nil
+}
+
+// Text redacts pattern matches and matching paths within free text.
+func (r *Redactor) Text(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+
+	if len(r.paths) > 0 {
+		s = r.textPaths(s)
+	}
+	return r.applyPatterns(s)
+}
+
+// textPaths redacts the paths within free text that match a path rule. A path
+// may contain spaces: it continues with the following words up to the last one
+// with a slash, or up to the first file name with an extension.
+func (r *Redactor) textPaths(s string) string {
+	var out strings.Builder
+	for {
+		loc := pathToken.FindStringIndex(s)
+		if loc == nil {
+			out.WriteString(s)
+			return out.String()
+		}
+		start, end := loc[0], loc[1]
+
+		longest := end
+		for next := end; !pathEnd.MatchString(s[start:next]); {
+			word := pathWord.FindString(s[next:])
+			if word == "" {
+				break
+			}
+			next += len(word)
+			if strings.Contains(word, "/") || pathEnd.MatchString(word) {
+				longest = next
+			}
+		}
+		if longest != end && r.matchesPath(s[start:longest]) {
+			end = longest
+		}
+
+		out.WriteString(s[:start])
+		if token := s[start:end]; r.matchesPath(token) {
+			out.WriteString(r.replace(token))
+		} else {
+			out.WriteString(token)
+		}
+		s = s[end:]
+	}
+}
+
+// Path redacts a file path as a whole when it matches a path rule, and pattern matches otherwise.
+func (r *Redactor) Path(path string) string {
+	if r == nil || path == "" {
+		return path
+	}
+
+	if r.matchesPath(path) {
+		return r.replace(path)
+	}
+	return r.applyPatterns(path)
+}
+
+// Event redacts the paths of an event and the content of canvas boards.
+func (r *Redactor) Event(event models.FileEvent) models.FileEvent {
+	if r == nil {
+		return event
+	}
+
+	event.FilePath = r.Path(event.FilePath)
+	event.VaultPath = r.Path(event.VaultPath)
+	if event.Canvas != nil {
+		event.Canvas = r.canvas(event.Canvas)
+	}
+	if event.LinkTarget != "" {
+		event.LinkTarget = r.Path(event.LinkTarget)
+	}
+	return event
+}
+
+// Conflict redacts the paths of a conflict.
+func (r *Redactor) Conflict(conflict models.Conflict) models.Conflict {
+	if r == nil {
+		return conflict
+	}
+
+	conflict.Path = r.Path(conflict.Path)
+	if conflict.Copy != "" {
+		conflict.Copy = r.Path(conflict.Copy)
+	}
+	return conflict
+}
+
+// canvas returns a redacted copy of a board, the original still goes to the sinks
+func (r *Redactor) canvas(c *models.Canvas) *models.Canvas {
+	redacted := &models.Canvas{
+		Texts: make([]models.CanvasText, len(c.Texts)),
+		Files: make([]models.CanvasFile, len(c.Files)),
+		Links: make([]models.CanvasLink, len(c.Links)),
+		Edges: make([]models.CanvasEdge, len(c.Edges)),
+	}
+	for i, text := range c.Texts {
+		redacted.Texts[i] = models.CanvasText{ID: text.ID, Text: r.Text(text.Text)}
+	}
+	for i, file := range c.Files {
+		redacted.Files[i] = models.CanvasFile{ID: file.ID, File: r.Path(file.File), Subpath: r.Text(file.Subpath)}
+	}
+	for i, link := range c.Links {
+		redacted.Links[i] = models.CanvasLink{ID: link.ID, URL: r.Text(link.URL)}
+	}
+	for i, edge := range c.Edges {
+		edge.Label = r.Text(edge.Label)
+		redacted.Edges[i] = edge
+	}
+	return redacted
+}
+
+func (r *Redactor) applyPatterns(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, r.replace)
+	}
+	return s
+}
+
+// matchesPath reports whether a path rule matches a component or trailing part of the path
+func (r *Redactor) matchesPath(path string) bool {
+	parts := strings.Split(filepath.Clean(filepath.FromSlash(path)), string(filepath.Separator))
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		suffix := filepath.Join(parts[i:]...)
+		for _, pattern := range r.paths {
+			if matched, _ := filepath.Match(pattern, part); matched {
+				return true
+			}
+			if matched, _ := filepath.Match(pattern, suffix); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Redactor) replace(s string) string {
+	if !r.hash {
+		return placeholder
+	}
+
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(s))
+	return "[sha256:" + hex.EncodeToString(mac.Sum(nil))[:12] + "]"
+}
@@ -0,0 +1,93 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestMaskPatternsAndPaths(t *testing.T) {
+	r, err := New(Rules{
+		Patterns: []string{`(?i)acme corp`, `\d{3}-\d{2}-\d{4}`},
+		Paths:    []string{"clients", "*.secret.md"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"pattern", r.Text("Meeting with ACME Corp about 123-45-6789"), "Meeting with [REDACTED] about [REDACTED]"},
+		{"folder", r.Path("/vault/clients/notes.md"), "[REDACTED]"},
+		{"file", r.Path("/vault/daily/plan.secret.md"), "[REDACTED]"},
+		{"unmatched path", r.Path("/vault/daily/acme corp.md"), "/vault/daily/[REDACTED].md"},
+		{"path in text", r.Text("Resynced /vault/clients/a.md and /vault/b.md"), "Resynced [REDACTED] and /vault/b.md"},
+		{"path with spaces", r.Text("Created /My Vault/clients/acme plan.md: done"), "Created [REDACTED]: done"},
+		{"folder with spaces", r.Text("Watching /My Vault/clients and /vault/b.md"), "Watching [REDACTED] and /vault/b.md"},
+		{"paths with spaces", r.Text("Moved /vault/My Notes/b.md to /vault/clients/c d.md"), "Moved /vault/My Notes/b.md to [REDACTED]"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("Expected %s to be '%s', got '%s'", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestHashModeIsStableAndSalted(t *testing.T) {
+	r, _ := New(Rules{Mode: ModeHash, Paths: []string{"clients"}})
+	salted, _ := New(Rules{Mode: ModeHash, Paths: []string{"clients"}, Salt: "pepper"})
+
+	first, second := r.Path("/vault/clients/a.md"), r.Path("/vault/clients/a.md")
+	if first != second || !strings.HasPrefix(first, "[sha256:") {
+		t.Errorf("Expected a stable hash, got '%s' and '%s'", first, second)
+	}
+	if other := r.Path("/vault/clients/b.md"); other == first {
+		t.Error("Expected different paths to hash differently")
+	}
+	if salted.Path("/vault/clients/a.md") == first {
+		t.Error("Expected the salt to change the hash")
+	}
+}
+
+func TestEventAndNilRedactor(t *testing.T) {
+	event := models.FileEvent{FilePath: "/vault/clients/a.md", VaultPath: "/vault"}
+
+	var none *Redactor
+	if got := none.Event(event); got != event {
+		t.Errorf("Expected a nil redactor to leave the event unchanged, got %+v", got)
+	}
+
+	r, _ := New(Rules{Paths: []string{"clients"}})
+	got := r.Event(event)
+	if got.FilePath != "[REDACTED]" || got.VaultPath != "/vault" {
+		t.Errorf("Expected only the file path to be redacted, got %+v", got)
+	}
+
+	// Canvas content is redacted in a copy, the sinks still get the original
+	board := &models.Canvas{
+		Texts: []models.CanvasText{{ID: "a1", Text: "Call ACME Corp"}},
+		Files: []models.CanvasFile{{ID: "b2", File: "clients/acme.md"}},
+	}
+	r, _ = New(Rules{Patterns: []string{`ACME Corp`}, Paths: []string{"clients"}})
+	got = r.Event(models.FileEvent{FilePath: "/vault/board.canvas", Canvas: board})
+	if got.Canvas.Texts[0].Text != "Call [REDACTED]" || got.Canvas.Files[0].File != "[REDACTED]" {
+		t.Errorf("Expected the canvas to be redacted, got %+v", got.Canvas)
+	}
+	if board.Texts[0].Text != "Call ACME Corp" {
+		t.Error("Expected the original canvas to be left unchanged")
+	}
+
+	conflict := r.Conflict(models.Conflict{Path: "clients/acme.md", Copy: "clients/acme (conflict).md", Policy: "keep-both"})
+	if conflict.Path != "[REDACTED]" || conflict.Copy != "[REDACTED]" || conflict.Policy != "keep-both" {
+		t.Errorf("Expected the conflict paths to be redacted, got %+v", conflict)
+	}
+
+	if r, err := New(Rules{}); r != nil || err != nil {
+		t.Errorf("Expected no redactor without rules, got %v, %v", r, err)
+	}
+	if _, err := New(Rules{Patterns: []string{"("}}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
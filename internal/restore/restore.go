@@ -0,0 +1,264 @@
+package restore
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/obsidian"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+)
+
+// Options select what to restore and how.
+type Options struct {
+	// Paths are vault-relative files or folders to restore; everything when empty
+	Paths []string
+	// Overwrite replaces existing files. Otherwise a file is only replaced when
+	// its content is the latest synced version, which the history still holds.
+	Overwrite bool
+	// Layout is how the vault is stored, uploader.LayoutPaths when empty
+	Layout string
+	// At restores the versions current at this time instead of the latest;
+	// it needs the content layout
+	At time.Time
+}
+
+// Result counts the restored and skipped files.
+type Result struct {
+	Restored int
+	Skipped  int
+}
+
+// Restore downloads a vault's files, stored under prefix, into dir. Encrypted
+// objects are decrypted when store is an uploader.EncryptedStore. Failed files
+// don't stop the restore and are reported together.
+func Restore(ctx context.Context, store uploader.ObjectStore, prefix, dir string, opts Options) (Result, error) {
+	var result Result
+
+	files, err := uploader.ListFiles(ctx, store, prefix, opts.Layout, opts.At)
+	if err != nil {
+		return result, err
+	}
+
+	// Links are made last, so a restored link can't redirect where files are written
+	links := make(map[string]string)
+
+	var errs []error
+	for _, file := range files {
+		rel, err := relativePath(file.Path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", file.Path, err))
+			continue
+		}
+		if !selected(rel, opts.Paths) {
+			continue
+		}
+
+		target := filepath.Join(dir, rel)
+		if !opts.Overwrite {
+			replace, err := replaceable(target, file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !replace {
+				logger.DebugWithFields("⏭️ Skipping existing file", logger.Fields{"path": target})
+				result.Skipped++
+				continue
+			}
+		}
+
+		body, metadata, _, err := store.Get(ctx, file.Key)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if file.Link || metadata[uploader.MetadataSymlink] == "true" {
+			links[target] = string(body)
+			continue
+		}
+		if err := writeFile(target, body); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.InfoWithFields("📥 Restored file", logger.Fields{"path": target, "key": file.Key})
+		result.Restored++
+	}
+
+	for target, link := range links {
+		if err := writeLink(target, link); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.InfoWithFields("📥 Restored symlink", logger.Fields{"path": target, "target": link})
+		result.Restored++
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// Settings extracts the settings bundle of the vault stored under prefix into
+// dir, replacing the settings there. Settings missing from the bundle are kept.
+func Settings(ctx context.Context, store uploader.ObjectStore, prefix, dir string) (int, error) {
+	body, _, _, err := store.Get(ctx, uploader.SettingsKey(prefix))
+	if errors.Is(err, uploader.ErrNotFound) {
+		return 0, errors.New("no settings were uploaded for this vault")
+	}
+	if err != nil {
+		return 0, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid settings bundle: %v", err)
+	}
+
+	restored := 0
+	var errs []error
+	for _, f := range zr.File {
+		rel, err := relativePath(f.Name)
+		if err == nil && !obsidian.IsSetting(rel) {
+			err = errors.New("not a setting")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.Name, err))
+			continue
+		}
+
+		content, err := readZipFile(f)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", f.Name, err))
+			continue
+		}
+		if err := writeFile(filepath.Join(dir, rel), content); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		restored++
+	}
+	logger.InfoWithFields("📥 Restored settings", logger.Fields{"path": dir, "files": restored})
+	return restored, errors.Join(errs...)
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// replaceable reports whether target can be written without losing local
+// changes: it doesn't exist, or holds the latest synced version and differs from the version to restore
+func replaceable(target string, file uploader.RemoteFile) (bool, error) {
+	if _, err := os.Lstat(target); errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if file.Synced == "" {
+		return false, nil
+	}
+
+	var body []byte
+	var err error
+	if file.Link {
+		var link string
+		link, err = os.Readlink(target)
+		body = []byte(link)
+	} else {
+		body, err = os.ReadFile(target)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %v", target, err)
+	}
+
+	sum := sha256.Sum256(body)
+	local := hex.EncodeToString(sum[:])
+	return local == file.Synced && local != file.Hash, nil
+}
+
+// relativePath converts a slash-separated vault path into a file path, rejecting paths that leave the vault
+func relativePath(path string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(path))
+	if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("not a path inside the vault")
+	}
+	return rel, nil
+}
+
+// selected reports whether rel is one of the paths or inside one of them
+func selected(rel string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, path := range paths {
+		path = filepath.Clean(filepath.FromSlash(path))
+		if rel == path || strings.HasPrefix(rel, path+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes through a temporary file, so an interrupted restore never leaves a partial note
+func writeFile(path string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %v", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeLink makes path a symlink to target, replacing what is there
+func writeLink(path, target string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %v", path, err)
+	}
+
+	// Reserve a temporary name, then put the link there
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to link %s: %v", path, err)
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+
+	if err := os.Symlink(target, tmp.Name()); err != nil {
+		return fmt.Errorf("failed to link %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to link %s: %v", path, err)
+	}
+	return nil
+}
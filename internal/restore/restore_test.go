@@ -0,0 +1,189 @@
+package restore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/encryption"
+	"github.com/aarangop/obsidian-sync/internal/uploader"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestRestoreEncryptedVault(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys")
+	if _, err := encryption.AddKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := encryption.OpenKeyFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	store := uploader.NewEncryptedStore(uploader.NewMemoryStore(), keys)
+	objects := map[string]string{
+		"work/note.md":          "# Note",
+		"work/Projects/plan.md": "# Plan",
+		"personal/diary.md":     "# Diary",
+	}
+	for key, content := range objects {
+		if err := store.Put(ctx, key, []byte(content), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	result, err := Restore(ctx, store, "work/", dir, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Restored != 2 {
+		t.Errorf("Expected 2 restored files, got %d", result.Restored)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "Projects", "plan.md"))
+	if err != nil || string(content) != "# Plan" {
+		t.Errorf("Expected '# Plan', got %q (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "diary.md")); err == nil {
+		t.Error("Expected other vaults not to be restored")
+	}
+
+	// Existing files are kept unless overwriting
+	if err := os.WriteFile(filepath.Join(dir, "note.md"), []byte("# Local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Restore(ctx, store, "work/", dir, Options{Paths: []string{"note.md"}})
+	if err != nil || result.Skipped != 1 || result.Restored != 0 {
+		t.Errorf("Expected the existing note to be skipped, got %+v (%v)", result, err)
+	}
+	result, err = Restore(ctx, store, "work/", dir, Options{Paths: []string{"note.md"}, Overwrite: true})
+	if err != nil || result.Restored != 1 {
+		t.Errorf("Expected the note to be restored, got %+v (%v)", result, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "note.md")); string(content) != "# Note" {
+		t.Errorf("Expected '# Note', got %q", content)
+	}
+}
+
+func TestRestoreRejectsKeysOutsideVault(t *testing.T) {
+	ctx := context.Background()
+	store := uploader.NewMemoryStore()
+	if err := store.Put(ctx, "work/../../escape.md", []byte("x"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "vault")
+	if _, err := Restore(ctx, store, "work/", dir, Options{}); err == nil {
+		t.Error("Expected an error for a key outside the vault")
+	}
+	if _, err := os.Stat(filepath.Join(root, "escape.md")); err == nil {
+		t.Error("Expected nothing to be written outside the vault")
+	}
+}
+
+func TestRestoreAtTime(t *testing.T) {
+	ctx := context.Background()
+	vault := t.TempDir()
+	store := uploader.NewMemoryStore()
+	sink := uploader.NewContent(store, vault, "")
+	sink.SetHistory(uploader.HistoryPolicy{Versions: 5})
+
+	note := filepath.Join(vault, "note.md")
+	write := func(content string) time.Time {
+		if err := os.WriteFile(note, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Send(ctx, models.FileEvent{EventType: models.EventModified, FilePath: note}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		synced := time.Now()
+		time.Sleep(10 * time.Millisecond)
+		return synced
+	}
+	draft := write("# Draft")
+	write("# Final")
+
+	opts := Options{Layout: uploader.LayoutContent, At: draft}
+
+	// Local changes that were never synced are kept
+	if err := os.WriteFile(note, []byte("# Unsynced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Restore(ctx, store, "", vault, opts)
+	if err != nil || result.Skipped != 1 {
+		t.Errorf("Expected the unsynced note to be skipped, got %+v (%v)", result, err)
+	}
+
+	// The synced version is in the history, so it can be replaced
+	write("# Final")
+	result, err = Restore(ctx, store, "", vault, opts)
+	if err != nil || result.Restored != 1 {
+		t.Fatalf("Expected the note to be restored, got %+v (%v)", result, err)
+	}
+	if content, _ := os.ReadFile(note); string(content) != "# Draft" {
+		t.Errorf("Expected '# Draft', got %q", content)
+	}
+
+	// Earlier versions need the content layout
+	if _, err := Restore(ctx, store, "", vault, Options{At: draft}); err == nil {
+		t.Error("Expected an error restoring an earlier time with the paths layout")
+	}
+}
+
+func TestRestoreSettings(t *testing.T) {
+	vault := t.TempDir()
+	app := filepath.Join(vault, ".obsidian", "app.json")
+	if err := os.MkdirAll(filepath.Dir(app), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(app, []byte(`{"vimMode": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	store := uploader.NewMemoryStore()
+	if err := uploader.NewSettingsBundle(store, vault, "work/").Send(ctx, models.FileEvent{FilePath: app}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	count, err := Settings(ctx, store, "work/", dir)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 restored setting, got %d (%v)", count, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, ".obsidian", "app.json")); string(content) != `{"vimMode": true}` {
+		t.Errorf("Expected the app settings, got %q", content)
+	}
+
+	if _, err := Settings(ctx, store, "personal/", dir); err == nil {
+		t.Error("Expected an error without uploaded settings")
+	}
+}
+
+func TestRestoreSymlinks(t *testing.T) {
+	ctx := context.Background()
+	vault := t.TempDir()
+	store := uploader.NewMemoryStore()
+	if err := store.Put(ctx, "shared", []byte("../shared"), map[string]string{uploader.MetadataSymlink: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(ctx, "note.md", []byte("# Note"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Restore(ctx, store, "", vault, Options{})
+	if err != nil || result.Restored != 2 {
+		t.Fatalf("Expected 2 restored files, got %+v (%v)", result, err)
+	}
+	if target, err := os.Readlink(filepath.Join(vault, "shared")); err != nil || target != "../shared" {
+		t.Errorf("Expected a link to ../shared, got %q (%v)", target, err)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+)
+
+// log is the server component logger
+var log = logger.Component("server")
+
+// Server is the embedded HTTP server exposing operational endpoints such as /metrics.
+type Server struct {
+	mux        *http.ServeMux
+	httpServer *http.Server
+}
+
+// New creates a server listening on the given port with the /metrics endpoint registered.
+func New(port int) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	// Long-lived requests such as event streams watch the base context,
+	// which is cancelled as soon as shutdown begins
+	baseCtx, cancel := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+	httpServer.RegisterOnShutdown(cancel)
+
+	return &Server{
+		mux:        mux,
+		httpServer: httpServer,
+	}
+}
+
+// Handle registers an additional handler for the given pattern.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start begins serving in the background. Errors after startup are logged.
+func (s *Server) Start() {
+	go func() {
+		log.Infof("🌐 HTTP server listening on %s", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("⚠️ HTTP server stopped: %v", err)
+		}
+	}()
+}
+
+// Stop gracefully shuts the server down.
+func (s *Server) Stop(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const recordFormat = 1
+
+// Record remembers the content hash of every file as it was last synced, in
+// either direction. A file whose hash differs from the record changed since,
+// locally or remotely. The record is saved after every change.
+type Record struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]string
+}
+
+type recordFile struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// Open reads the record at path, starting an empty one if it doesn't exist yet.
+func Open(path string) (*Record, error) {
+	r := &Record{path: path, files: make(map[string]string)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync record %s: %v", path, err)
+	}
+
+	var stored recordFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid sync record %s: %v", path, err)
+	}
+	if stored.Version > recordFormat {
+		return nil, fmt.Errorf("sync record %s has format %d, this version supports up to %d", path, stored.Version, recordFormat)
+	}
+	if stored.Files != nil {
+		r.files = stored.Files
+	}
+	return r, nil
+}
+
+// Get returns the hash a file had when it was last synced.
+func (r *Record) Get(path string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hash, exists := r.files[path]
+	return hash, exists
+}
+
+// Set records that a file is in sync with the given content hash.
+func (r *Record) Set(path, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files[path] == hash {
+		return nil
+	}
+	r.files[path] = hash
+	return r.save()
+}
+
+// Delete records that a file is gone on both sides.
+func (r *Record) Delete(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.files[path]; !exists {
+		return nil
+	}
+	delete(r.files, path)
+	return r.save()
+}
+
+// Paths returns the recorded paths, sorted.
+func (r *Record) Paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	paths := make([]string, 0, len(r.files))
+	for path := range r.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// save writes the record through a temporary file, so a crash never leaves it half written
+func (r *Record) save() error {
+	data, err := json.MarshalIndent(recordFile{Version: recordFormat, Files: r.files}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to save sync record: %v", err)
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save sync record: %v", err)
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return fmt.Errorf("failed to save sync record: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "work.json")
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Expected a missing record to open empty, got %v", err)
+	}
+	if err := r.Set("note.md", "aa"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("plan.md", "bb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete("plan.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if hash, _ := r.Get("note.md"); hash != "aa" {
+		t.Errorf("Expected hash 'aa', got '%s'", hash)
+	}
+	if paths := r.Paths(); len(paths) != 1 || paths[0] != "note.md" {
+		t.Errorf("Expected only note.md, got %v", paths)
+	}
+}
+
+func TestRecordRejectsNewerFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "work.json")
+	if err := os.WriteFile(path, []byte(`{"version": 99, "files": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Expected an error for a newer record format")
+	}
+}
@@ -0,0 +1,327 @@
+package uploader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/internal/state"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// saveAttempts is how often changes are applied to a freshly read manifest when
+// other writers keep saving it in between
+const saveAttempts = 5
+
+// maxBatch is how many changes are made to the manifest in memory before it is
+// saved without waiting for Commit
+const maxBatch = 500
+
+// orphanGracePeriod is how long an unreferenced blob is kept before it is deleted,
+// so content that comes back, e.g. through a rename seen as delete and create, isn't uploaded again
+const orphanGracePeriod = time.Hour
+
+// ContentUploader mirrors vault files into an object store with the content
+// layout: each distinct content is stored once as a blob named after its
+// SHA-256 hash, and a manifest per vault maps paths to hashes. Renames, copies
+// and reverts only update the manifest.
+type ContentUploader struct {
+	store     ObjectStore
+	vaultPath string
+	prefix    string
+
+	// mu guards the fields below; the manifest is loaded on the first event
+	mu       sync.Mutex
+	manifest *Manifest
+	// pending are the changes to the manifest in memory that aren't saved yet,
+	// applied again whenever the manifest is read again. batch holds the events
+	// behind them, synced again when another writer saved the manifest in between,
+	// and synced the hashes they leave their paths at, recorded once saved.
+	pending []func(m *Manifest)
+	batch   []models.FileEvent
+	synced  map[string]string
+	history HistoryPolicy
+	record  *state.Record
+	now     func() time.Time
+}
+
+func NewContent(store ObjectStore, vaultPath, prefix string) *ContentUploader {
+	return &ContentUploader{
+		store:     store,
+		vaultPath: vaultPath,
+		prefix:    prefix,
+		synced:    make(map[string]string),
+		now:       time.Now,
+	}
+}
+
+// SetHistory sets how many earlier versions of each file are kept, taking effect on the next change.
+func (c *ContentUploader) SetHistory(policy HistoryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = policy
+}
+
+// SetRecord makes the uploader keep the sync record current. Other writers may
+// then change the manifest too, so it is read again before every batch of changes.
+func (c *ContentUploader) SetRecord(record *state.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.record = record
+}
+
+// WithManifest saves the pending changes and reads the manifest again, picking
+// up changes by other writers, and calls fn with the files it lists. Uploads wait
+// until fn returns, so fn can act on the files and the sync record without an
+// upload changing either in between.
+func (c *ContentUploader) WithManifest(ctx context.Context, fn func(files map[string]ManifestEntry) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.commit(ctx); err != nil {
+		return err
+	}
+	if err := c.load(ctx, true); err != nil {
+		return err
+	}
+	files := make(map[string]ManifestEntry, len(c.manifest.Files))
+	for path, entry := range c.manifest.Files {
+		files[path] = entry
+	}
+	return fn(files)
+}
+
+// Name implements pipeline.Sink
+func (c *ContentUploader) Name() string {
+	return "s3"
+}
+
+// Send uploads the content of created or modified files unless it is already
+// stored, and updates the manifest in memory. The manifest is saved by Commit,
+// or once maxBatch changes are pending.
+func (c *ContentUploader) Send(ctx context.Context, event models.FileEvent) error {
+	path, err := relativeKey(c.vaultPath, event.FilePath)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A batch starts from the manifest as other writers left it
+	if err := c.load(ctx, c.record != nil && len(c.batch) == 0); err != nil {
+		return err
+	}
+	if err := c.send(ctx, path, event); err != nil {
+		return err
+	}
+	if len(c.batch) >= maxBatch {
+		return c.commit(ctx)
+	}
+	return nil
+}
+
+// Commit saves the changes made to the manifest since it was last saved. The
+// pipeline commits once its queue is drained, so a burst of events such as the
+// first sync of a vault saves the manifest once instead of after every file.
+// When another writer saved the manifest in the meantime, it is read again and
+// the events synced again on top of it.
+func (c *ContentUploader) Commit(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.commit(ctx)
+}
+
+func (c *ContentUploader) commit(ctx context.Context) error {
+	for attempt := 1; len(c.pending) > 0; attempt++ {
+		err := c.save(ctx)
+		if !errors.Is(err, ErrConflict) || attempt == saveAttempts {
+			return err
+		}
+		log.DebugWithFields("🔁 Manifest changed by another writer, syncing again", logger.Fields{"files": len(c.batch), "attempt": attempt, "sink": c.Name()})
+		if err := c.resend(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resend reads the manifest again and syncs the events of the batch on top of
+// it. Each is decided again, so a file another writer changed in between is left
+// to the next pull.
+func (c *ContentUploader) resend(ctx context.Context) error {
+	manifest, err := LoadManifest(ctx, c.store, c.prefix)
+	if err != nil {
+		return err
+	}
+	batch := c.batch
+	c.manifest = manifest
+	c.pending, c.batch, c.synced = nil, nil, make(map[string]string)
+
+	for _, event := range batch {
+		path, err := relativeKey(c.vaultPath, event.FilePath)
+		if err == nil {
+			err = c.send(ctx, path, event)
+		}
+		if err != nil {
+			log.WarnWithFields("⚠️ Failed to sync file again", logger.Fields{"path": event.FilePath, "error": err, "sink": c.Name()})
+		}
+	}
+	return nil
+}
+
+// send applies an event to the manifest in memory, which is loaded
+func (c *ContentUploader) send(ctx context.Context, path string, event models.FileEvent) error {
+	now := c.now()
+
+	if event.EventType == models.EventDeleted {
+		if c.changedRemotely(path, "") {
+			return nil
+		}
+		if _, exists := c.manifest.Files[path]; exists {
+			c.change(event, func(m *Manifest) { m.Remove(path, now) })
+		}
+		c.recordSynced(path, "")
+		return nil
+	}
+
+	body, err := readContent(event)
+	if errors.Is(err, os.ErrNotExist) {
+		// The file disappeared before we got to it; its delete event will follow
+		log.DebugWithFields("🤷 Skipping upload of vanished file", logger.Fields{"path": event.FilePath, "sink": c.Name()})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", event.FilePath, err)
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	link := event.LinkTarget != ""
+	if entry, exists := c.manifest.Files[path]; exists && entry.Hash == hash && entry.Link == link {
+		log.DebugWithFields("⏭️ Content unchanged, nothing to upload", logger.Fields{"path": event.FilePath, "sink": c.Name()})
+		c.recordSynced(path, hash)
+		return nil
+	}
+	if c.changedRemotely(path, hash) {
+		return nil
+	}
+
+	key := BlobKey(c.prefix, hash)
+	if c.manifest.HasBlob(hash) {
+		log.DebugWithFields("♻️  Content already stored, updating manifest", logger.Fields{"path": event.FilePath, "key": key, "sink": c.Name()})
+	} else {
+		if err := c.store.Put(ctx, key, body, nil); err != nil {
+			return err
+		}
+		metrics.UploadedBytes.WithLabelValues(c.Name()).Add(float64(len(body)))
+		log.DebugWithFields("☁️  Uploaded file", logger.Fields{"path": event.FilePath, "key": key, "bytes": len(body), "sink": c.Name()})
+	}
+
+	entry := ManifestEntry{Hash: hash, Size: int64(len(body)), Modified: now, Link: link}
+	c.change(event, func(m *Manifest) { m.Set(path, entry, now) })
+	c.recordSynced(path, hash)
+	return nil
+}
+
+// load reads the manifest if it isn't loaded yet, or again when reload is set,
+// applying the changes that aren't saved yet on top
+func (c *ContentUploader) load(ctx context.Context, reload bool) error {
+	if c.manifest != nil && !reload {
+		return nil
+	}
+	manifest, err := LoadManifest(ctx, c.store, c.prefix)
+	if err != nil {
+		return err
+	}
+	for _, change := range c.pending {
+		change(manifest)
+	}
+	c.manifest = manifest
+	return nil
+}
+
+// change applies the change an event makes to the manifest in memory, to be saved by the next commit
+func (c *ContentUploader) change(event models.FileEvent, change func(m *Manifest)) {
+	change(c.manifest)
+	c.pending = append(c.pending, change)
+	c.batch = append(c.batch, event)
+}
+
+// changedRemotely reports whether another writer changed the file since it was
+// last synced, so the local change is a conflict left for the next pull to resolve
+func (c *ContentUploader) changedRemotely(path, hash string) bool {
+	if c.record == nil {
+		return false
+	}
+	// An empty hash stands for a deleted file on either side
+	remote := c.manifest.Files[path].Hash
+	synced, pending := c.synced[path]
+	if !pending {
+		synced, _ = c.record.Get(path)
+	}
+	if remote == synced || remote == hash {
+		return false
+	}
+	log.WarnWithFields("⚔️  Changed locally and remotely, leaving it to the next pull", logger.Fields{"path": path, "local": hash, "remote": remote, "sink": c.Name()})
+	return true
+}
+
+// recordSynced updates the sync record, if any, once the pending changes are
+// saved; an empty hash records a deletion
+func (c *ContentUploader) recordSynced(path, hash string) {
+	if c.record == nil {
+		return
+	}
+	if len(c.batch) > 0 {
+		c.synced[path] = hash
+		return
+	}
+	c.writeRecord(path, hash)
+}
+
+func (c *ContentUploader) writeRecord(path, hash string) {
+	var err error
+	if hash == "" {
+		err = c.record.Delete(path)
+	} else {
+		err = c.record.Set(path, hash)
+	}
+	if err != nil {
+		log.WarnWithFields("⚠️ Failed to update sync record", logger.Fields{"path": path, "error": err})
+	}
+}
+
+// save prunes the history and writes the manifest, records the synced files,
+// then deletes the blobs orphaned for longer than the grace period. The manifest
+// in memory stays current when saving fails, so the next commit saves it.
+func (c *ContentUploader) save(ctx context.Context) error {
+	c.manifest.Prune(c.history, c.now())
+	expired := c.manifest.ExpiredOrphans(c.now().Add(-orphanGracePeriod))
+	for _, hash := range expired {
+		delete(c.manifest.Orphans, hash)
+	}
+	if err := c.manifest.Save(ctx, c.store, c.prefix); err != nil {
+		return err
+	}
+	c.pending, c.batch = nil, nil
+	for path, hash := range c.synced {
+		c.writeRecord(path, hash)
+	}
+	c.synced = make(map[string]string)
+
+	// A blob that fails to delete is only wasted space
+	for _, hash := range expired {
+		if err := c.store.Delete(ctx, BlobKey(c.prefix, hash)); err != nil {
+			log.WarnWithFields("⚠️ Failed to delete unused blob", logger.Fields{"key": BlobKey(c.prefix, hash), "error": err})
+		}
+	}
+	return nil
+}
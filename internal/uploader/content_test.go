@@ -0,0 +1,270 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// contentVault is a vault folder uploaded with the content layout to a memory store
+type contentVault struct {
+	t     *testing.T
+	dir   string
+	store *MemoryStore
+	sink  *ContentUploader
+	now   time.Time
+}
+
+func newContentVault(t *testing.T) *contentVault {
+	v := &contentVault{
+		t:     t,
+		dir:   t.TempDir(),
+		store: NewMemoryStore(),
+		now:   time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC),
+	}
+	v.sink = v.uploader()
+	return v
+}
+
+// uploader returns a fresh uploader, as after a restart
+func (v *contentVault) uploader() *ContentUploader {
+	u := NewContent(v.store, v.dir, "work/")
+	u.now = func() time.Time { return v.now }
+	return u
+}
+
+func (v *contentVault) write(name, content string) {
+	path := filepath.Join(v.dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		v.t.Fatal(err)
+	}
+	v.send(models.EventModified, name)
+}
+
+func (v *contentVault) remove(name string) {
+	if err := os.Remove(filepath.Join(v.dir, name)); err != nil {
+		v.t.Fatal(err)
+	}
+	v.send(models.EventDeleted, name)
+}
+
+// send delivers an event and commits it, as the pipeline does once its queue is drained
+func (v *contentVault) send(eventType models.EventType, name string) {
+	v.t.Helper()
+	event := models.FileEvent{EventType: eventType, FilePath: filepath.Join(v.dir, name)}
+	if err := v.sink.Send(context.Background(), event); err != nil {
+		v.t.Fatalf("Expected no error sending %s, got %v", name, err)
+	}
+	if err := v.sink.Commit(context.Background()); err != nil {
+		v.t.Fatalf("Expected no error committing %s, got %v", name, err)
+	}
+}
+
+func (v *contentVault) blobs() int {
+	keys, _ := v.store.List(context.Background(), "work/blobs/")
+	return len(keys)
+}
+
+func (v *contentVault) manifest() *Manifest {
+	m, err := LoadManifest(context.Background(), v.store, "work/")
+	if err != nil {
+		v.t.Fatalf("Failed to load manifest: %v", err)
+	}
+	return m
+}
+
+func TestContentLayoutDeduplicates(t *testing.T) {
+	v := newContentVault(t)
+
+	v.write("photo.png", "image bytes")
+	v.write("copy.png", "image bytes")
+	if v.blobs() != 1 {
+		t.Errorf("Expected identical files to share 1 blob, got %d", v.blobs())
+	}
+
+	// A rename arrives as a delete and a create, the content is not uploaded again
+	if err := os.Rename(filepath.Join(v.dir, "copy.png"), filepath.Join(v.dir, "renamed.png")); err != nil {
+		t.Fatal(err)
+	}
+	v.send(models.EventDeleted, "copy.png")
+	v.send(models.EventCreated, "renamed.png")
+	if v.blobs() != 1 {
+		t.Errorf("Expected the rename to reuse the blob, got %d blobs", v.blobs())
+	}
+
+	m := v.manifest()
+	if _, exists := m.Files["copy.png"]; exists {
+		t.Error("Expected copy.png to be removed from the manifest")
+	}
+	if m.Files["renamed.png"].Hash != m.Files["photo.png"].Hash {
+		t.Error("Expected renamed.png to point at the same blob as photo.png")
+	}
+}
+
+func TestContentLayoutKeepsOrphansForReverts(t *testing.T) {
+	v := newContentVault(t)
+
+	v.write("note.md", "# Draft")
+	v.write("note.md", "# Final")
+	if v.blobs() != 2 {
+		t.Fatalf("Expected 2 blobs, got %d", v.blobs())
+	}
+
+	// Reverting within the grace period reuses the old blob
+	v.now = v.now.Add(10 * time.Minute)
+	v.write("note.md", "# Draft")
+	if v.blobs() != 2 {
+		t.Errorf("Expected the revert to reuse the blob, got %d blobs", v.blobs())
+	}
+
+	// Orphans are deleted once the grace period is over
+	v.now = v.now.Add(2 * orphanGracePeriod)
+	v.write("other.md", "# Other")
+	if v.blobs() != 2 {
+		t.Errorf("Expected the expired orphan to be deleted, got %d blobs", v.blobs())
+	}
+	if len(v.manifest().Orphans) != 0 {
+		t.Errorf("Expected no orphans, got %v", v.manifest().Orphans)
+	}
+}
+
+func TestContentLayoutResumesFromManifest(t *testing.T) {
+	v := newContentVault(t)
+	v.write("note.md", "# Note")
+
+	// After a restart unchanged files are recognised from the stored manifest
+	v.sink = v.uploader()
+	before := v.manifest().Files["note.md"].Modified
+	v.now = v.now.Add(time.Hour)
+	v.send(models.EventModified, "note.md")
+
+	if modified := v.manifest().Files["note.md"].Modified; !modified.Equal(before) {
+		t.Errorf("Expected the unchanged note not to be uploaded again, modified changed to %v", modified)
+	}
+
+	files, err := ListFiles(context.Background(), v.store, "work/", LayoutContent, time.Time{})
+	if err != nil || len(files) != 1 || files[0].Path != "note.md" {
+		t.Fatalf("Expected note.md to be listed, got %v (%v)", files, err)
+	}
+	body, _, _, err := v.store.Get(context.Background(), files[0].Key)
+	if err != nil || string(body) != "# Note" {
+		t.Errorf("Expected '# Note', got %q (%v)", body, err)
+	}
+
+	v.remove("note.md")
+	if len(v.manifest().Files) != 0 {
+		t.Errorf("Expected an empty manifest, got %v", v.manifest().Files)
+	}
+}
+
+func TestContentLayoutSavesManifestOncePerCommit(t *testing.T) {
+	v := newContentVault(t)
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(v.dir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		event := models.FileEvent{EventType: models.EventCreated, FilePath: filepath.Join(v.dir, name)}
+		if err := v.sink.Send(context.Background(), event); err != nil {
+			t.Fatalf("Expected no error sending %s, got %v", name, err)
+		}
+	}
+	if files := v.manifest().Files; len(files) != 0 {
+		t.Errorf("Expected the manifest to wait for the commit, got %v", files)
+	}
+
+	if err := v.sink.Commit(context.Background()); err != nil {
+		t.Fatalf("Expected no error committing, got %v", err)
+	}
+	if files := v.manifest().Files; len(files) != 3 {
+		t.Errorf("Expected 3 files in the manifest, got %v", files)
+	}
+	// Three blobs and a single manifest
+	if v.store.writes != 4 {
+		t.Errorf("Expected 4 writes, got %d", v.store.writes)
+	}
+}
+
+func TestContentLayoutMergesConcurrentWriters(t *testing.T) {
+	v := newContentVault(t)
+	laptop := v.sink
+	desktop := v.uploader()
+
+	// Each writer keeps the manifest it read first, so every later save of
+	// the other one finds it changed
+	v.write("a.md", "# A")
+	v.sink = desktop
+	v.write("b.md", "# B")
+	v.sink = laptop
+	v.write("c.md", "# C")
+	v.sink = desktop
+	v.remove("a.md")
+
+	files := v.manifest().Files
+	if len(files) != 2 || files["b.md"].Hash == "" || files["c.md"].Hash == "" {
+		t.Errorf("Expected b.md and c.md in the manifest, got %v", files)
+	}
+}
+
+func TestContentUploadsWaitForWithManifest(t *testing.T) {
+	v := newContentVault(t)
+	v.write("note.md", "# Note")
+	if err := os.WriteFile(filepath.Join(v.dir, "note.md"), []byte("# Edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan struct{})
+	err := v.sink.WithManifest(context.Background(), func(files map[string]ManifestEntry) error {
+		before := files["note.md"].Hash
+		go func() {
+			event := models.FileEvent{EventType: models.EventModified, FilePath: filepath.Join(v.dir, "note.md")}
+			if err := v.sink.Send(context.Background(), event); err != nil {
+				t.Errorf("Expected no error sending note.md, got %v", err)
+			}
+			if err := v.sink.Commit(context.Background()); err != nil {
+				t.Errorf("Expected no error committing note.md, got %v", err)
+			}
+			close(sent)
+		}()
+		select {
+		case <-sent:
+			t.Error("Expected the upload to wait until the manifest is released")
+		case <-time.After(50 * time.Millisecond):
+		}
+		if hash := v.manifest().Files["note.md"].Hash; hash != before {
+			t.Errorf("Expected the manifest to stay at %s, got %s", before, hash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-sent
+	if v.manifest().Files["note.md"].Size != int64(len("# Edited")) {
+		t.Error("Expected the edit to be uploaded afterwards")
+	}
+}
+
+func TestContentUploadsSymlinksAsLinks(t *testing.T) {
+	v := newContentVault(t)
+	if err := os.Symlink("../shared", filepath.Join(v.dir, "shared")); err != nil {
+		t.Fatal(err)
+	}
+	event := models.FileEvent{EventType: models.EventCreated, FilePath: filepath.Join(v.dir, "shared"), LinkTarget: "../shared"}
+	if err := v.sink.Send(context.Background(), event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	entry := v.sink.manifest.Files["shared"]
+	if !entry.Link {
+		t.Fatal("Expected the manifest entry to be a link")
+	}
+	body, _, _, err := v.store.Get(context.Background(), BlobKey("work/", entry.Hash))
+	if err != nil || string(body) != "../shared" {
+		t.Errorf("Expected the blob to hold the link target, got %q (%v)", body, err)
+	}
+}
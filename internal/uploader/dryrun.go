@@ -0,0 +1,155 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+)
+
+// Operations of a planned Action, one per ObjectStore method
+const (
+	OpPut    = "put"
+	OpGet    = "get"
+	OpDelete = "delete"
+	OpList   = "list"
+)
+
+// Action is a call to the bucket planned in dry-run mode.
+type Action struct {
+	Time   time.Time `json:"time"`
+	Vault  string    `json:"vault,omitempty"`
+	Bucket string    `json:"bucket"`
+	Op     string    `json:"op"`
+	// Key is the object's key, or the listed prefix
+	Key      string            `json:"key"`
+	Size     int64             `json:"size,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Plan collects the actions of a dry run. Uploads and deletes are logged as
+// they are planned, and every action is written to out as a line of JSON.
+type Plan struct {
+	mu      sync.Mutex
+	out     *json.Encoder
+	failed  bool
+	counts  map[string]int
+	written int64
+}
+
+// NewPlan creates a plan exporting its actions to out, or only logging them when out is nil.
+func NewPlan(out io.Writer) *Plan {
+	p := &Plan{counts: make(map[string]int)}
+	if out != nil {
+		p.out = json.NewEncoder(out)
+	}
+	return p
+}
+
+// Record adds an action to the plan.
+func (p *Plan) Record(action Action) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.counts[action.Op]++
+	fields := logger.Fields{"vault": action.Vault, "bucket": action.Bucket, "key": action.Key}
+	switch action.Op {
+	case OpPut:
+		p.written += action.Size
+		fields["bytes"] = action.Size
+		log.InfoWithFields("📝 Would upload", fields)
+	case OpDelete:
+		log.InfoWithFields("📝 Would delete", fields)
+	default:
+		log.DebugWithFields("📝 Would call "+action.Op, fields)
+	}
+
+	if p.out == nil || p.failed {
+		return
+	}
+	if err := p.out.Encode(action); err != nil {
+		// Keep planning, the log still shows what would happen
+		p.failed = true
+		log.Warnf("⚠️ Failed to export the dry-run plan: %v", err)
+	}
+}
+
+// Count returns how many actions of an operation were planned.
+func (p *Plan) Count(op string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts[op]
+}
+
+// LogSummary logs how many uploads and deletes were planned.
+func (p *Plan) LogSummary() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	log.InfoWithFields("📝 Dry run finished", logger.Fields{
+		"uploads": p.counts[OpPut],
+		"deletes": p.counts[OpDelete],
+		"calls":   p.counts[OpPut] + p.counts[OpGet] + p.counts[OpDelete] + p.counts[OpList],
+		"bytes":   p.written,
+	})
+}
+
+// RecordingStore stands in for a bucket in dry-run mode: every call is recorded
+// in the plan instead of being made. It starts out empty, like a new bucket, and
+// keeps what would be uploaded in memory, so the uploaders see their own
+// earlier writes, such as the manifest, and skip content that is already stored.
+type RecordingStore struct {
+	memory  *MemoryStore
+	plan    *Plan
+	bucket  string
+	vaultID string
+}
+
+// NewRecordingStore creates a store recording the calls a vault makes to bucket.
+func NewRecordingStore(plan *Plan, bucket, vaultID string) *RecordingStore {
+	return &RecordingStore{
+		memory:  NewMemoryStore(),
+		plan:    plan,
+		bucket:  bucket,
+		vaultID: vaultID,
+	}
+}
+
+func (r *RecordingStore) Put(ctx context.Context, key string, body []byte, metadata map[string]string) error {
+	r.record(OpPut, key, int64(len(body)), metadata)
+	return r.memory.Put(ctx, key, body, metadata)
+}
+
+func (r *RecordingStore) PutIfMatch(ctx context.Context, key string, body []byte, metadata map[string]string, version string) (string, error) {
+	r.record(OpPut, key, int64(len(body)), metadata)
+	return r.memory.PutIfMatch(ctx, key, body, metadata, version)
+}
+
+func (r *RecordingStore) Get(ctx context.Context, key string) ([]byte, map[string]string, string, error) {
+	r.record(OpGet, key, 0, nil)
+	return r.memory.Get(ctx, key)
+}
+
+func (r *RecordingStore) Delete(ctx context.Context, key string) error {
+	r.record(OpDelete, key, 0, nil)
+	return r.memory.Delete(ctx, key)
+}
+
+func (r *RecordingStore) List(ctx context.Context, prefix string) ([]string, error) {
+	r.record(OpList, prefix, 0, nil)
+	return r.memory.List(ctx, prefix)
+}
+
+func (r *RecordingStore) record(op, key string, size int64, metadata map[string]string) {
+	r.plan.Record(Action{
+		Time:     time.Now().UTC(),
+		Vault:    r.vaultID,
+		Bucket:   r.bucket,
+		Op:       op,
+		Key:      key,
+		Size:     size,
+		Metadata: cloneMetadata(metadata),
+	})
+}
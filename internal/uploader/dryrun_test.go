@@ -0,0 +1,81 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestRecordingStorePlansUploads(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	plan := NewPlan(&out)
+	sink := NewContent(NewRecordingStore(plan, "notes-bucket", "work"), dir, "work/")
+
+	for _, name := range []string{"a.md", "b.md"} {
+		// Same content, so the blob is planned once
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# Same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		event := models.FileEvent{EventType: models.EventModified, FilePath: filepath.Join(dir, name)}
+		if err := sink.Send(context.Background(), event); err != nil {
+			t.Errorf("Expected no error sending %s, got %v", name, err)
+		}
+	}
+	if err := sink.Commit(context.Background()); err != nil {
+		t.Fatalf("Expected no error committing, got %v", err)
+	}
+
+	// One blob and the manifest, saved once for both files
+	if got := plan.Count(OpPut); got != 2 {
+		t.Errorf("Expected 2 planned uploads, got %d", got)
+	}
+	if got := plan.Count(OpGet); got != 1 {
+		t.Errorf("Expected the manifest to be read once, got %d", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 exported actions, got %d", len(lines))
+	}
+	var blob Action
+	if err := json.Unmarshal([]byte(lines[1]), &blob); err != nil {
+		t.Fatalf("Expected an action as JSON, got %q: %v", lines[1], err)
+	}
+	if blob.Op != OpPut || blob.Bucket != "notes-bucket" || blob.Vault != "work" || blob.Size != int64(len("# Same")) {
+		t.Errorf("Expected the blob upload to be exported, got %+v", blob)
+	}
+	if !strings.HasPrefix(blob.Key, "work/") {
+		t.Errorf("Expected the blob key under the vault prefix, got %s", blob.Key)
+	}
+}
+
+func TestRecordingStoreKeepsWritesInMemory(t *testing.T) {
+	store := NewRecordingStore(NewPlan(nil), "notes-bucket", "work")
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "work/a.md", []byte("a"), nil); err != nil {
+		t.Fatal(err)
+	}
+	body, _, _, err := store.Get(ctx, "work/a.md")
+	if err != nil || string(body) != "a" {
+		t.Errorf("Expected the planned upload to be readable, got %q, %v", body, err)
+	}
+
+	if err := store.Delete(ctx, "work/a.md"); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := store.List(ctx, "work/")
+	if err != nil || len(keys) != 0 {
+		t.Errorf("Expected no keys after the planned delete, got %v, %v", keys, err)
+	}
+	if got := store.plan.Count(OpDelete); got != 1 {
+		t.Errorf("Expected 1 planned delete, got %d", got)
+	}
+}
@@ -0,0 +1,108 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aarangop/obsidian-sync/internal/encryption"
+	"github.com/aarangop/obsidian-sync/internal/logger"
+)
+
+// Metadata keys describing how an object is encrypted
+const (
+	MetadataEncryption = "encryption"
+	MetadataKeyID      = "encryption-key-id"
+)
+
+// ErrPlaintext is returned by EncryptedStore.Get for objects that aren't encrypted
+var ErrPlaintext = errors.New("object is not encrypted")
+
+// EncryptedStore encrypts objects before they reach the wrapped store and
+// decrypts them on the way back, with keys from a local key file.
+type EncryptedStore struct {
+	ObjectStore
+	keys *encryption.KeyFile
+	// allowPlaintext accepts objects uploaded before encryption was enabled
+	allowPlaintext bool
+}
+
+func NewEncryptedStore(store ObjectStore, keys *encryption.KeyFile) *EncryptedStore {
+	return &EncryptedStore{ObjectStore: store, keys: keys}
+}
+
+// SetAllowPlaintext makes Get return unencrypted objects as they are instead of
+// failing. Anyone who can write to the bucket can then change the vault's
+// content unnoticed, so it is only meant for buckets with objects uploaded
+// before encryption was enabled.
+func (e *EncryptedStore) SetAllowPlaintext(allow bool) {
+	e.allowPlaintext = allow
+}
+
+// Put encrypts body with the active key and records the key ID in the metadata.
+func (e *EncryptedStore) Put(ctx context.Context, key string, body []byte, metadata map[string]string) error {
+	sealed, withKey, err := e.seal(key, body, metadata)
+	if err != nil {
+		return err
+	}
+	return e.ObjectStore.Put(ctx, key, sealed, withKey)
+}
+
+// PutIfMatch is Put with the wrapped store's precondition.
+func (e *EncryptedStore) PutIfMatch(ctx context.Context, key string, body []byte, metadata map[string]string, version string) (string, error) {
+	sealed, withKey, err := e.seal(key, body, metadata)
+	if err != nil {
+		return "", err
+	}
+	return e.ObjectStore.PutIfMatch(ctx, key, sealed, withKey, version)
+}
+
+// seal encrypts body with the active key, returning it with metadata naming the key
+func (e *EncryptedStore) seal(key string, body []byte, metadata map[string]string) ([]byte, map[string]string, error) {
+	keyring, err := e.keys.Keyring()
+	if keyring == nil {
+		return nil, nil, err
+	}
+	if err != nil {
+		// The key file changed but can't be read, keep encrypting with the previous keys
+		log.WarnWithFields("⚠️ Using previous encryption keys", logger.Fields{"error": err})
+	}
+
+	sealed, err := keyring.Seal(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encrypt %s: %v", key, err)
+	}
+
+	withKey := cloneMetadata(metadata)
+	if withKey == nil {
+		withKey = make(map[string]string, 2)
+	}
+	withKey[MetadataEncryption] = encryption.Algorithm
+	withKey[MetadataKeyID] = keyring.ActiveKeyID()
+	return sealed, withKey, nil
+}
+
+// Get decrypts and authenticates objects. Unencrypted objects fail with an error
+// wrapping ErrPlaintext, unless allowed with SetAllowPlaintext.
+func (e *EncryptedStore) Get(ctx context.Context, key string) ([]byte, map[string]string, string, error) {
+	body, metadata, version, err := e.ObjectStore.Get(ctx, key)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if metadata[MetadataEncryption] == "" && !encryption.IsEncrypted(body) {
+		if !e.allowPlaintext {
+			return nil, nil, "", fmt.Errorf("%s: %w", key, ErrPlaintext)
+		}
+		return body, metadata, version, nil
+	}
+
+	keyring, err := e.keys.Keyring()
+	if keyring == nil {
+		return nil, nil, "", err
+	}
+	plaintext, err := keyring.Open(body)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to decrypt %s: %v", key, err)
+	}
+	return plaintext, metadata, version, nil
+}
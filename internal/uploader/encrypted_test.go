@@ -0,0 +1,79 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aarangop/obsidian-sync/internal/encryption"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestEncryptedUpload(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys")
+	id, err := encryption.AddKey(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := encryption.OpenKeyFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault := t.TempDir()
+	note := filepath.Join(vault, "Journal", "today.md")
+	content := []byte("# Today\n\nNothing to see here")
+	if err := os.MkdirAll(filepath.Dir(note), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(note, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	memory := NewMemoryStore()
+	store := NewEncryptedStore(memory, keys)
+	u := New(store, vault, "work/")
+
+	ctx := context.Background()
+	if err := u.Send(ctx, models.FileEvent{EventType: models.EventCreated, FilePath: note}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The bucket only sees ciphertext and the key ID
+	stored, metadata, _, err := memory.Get(ctx, "work/Journal/today.md")
+	if err != nil {
+		t.Fatalf("Expected the object to be stored, got %v", err)
+	}
+	if bytes.Contains(stored, content) {
+		t.Error("Expected the stored object to be encrypted")
+	}
+	if metadata[MetadataKeyID] != id || metadata[MetadataEncryption] != encryption.Algorithm {
+		t.Errorf("Expected key ID %s and algorithm %s in metadata, got %v", id, encryption.Algorithm, metadata)
+	}
+
+	// Reading through the encrypted store decrypts
+	body, _, _, err := store.Get(ctx, "work/Journal/today.md")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(body, content) {
+		t.Errorf("Expected %q, got %q", content, body)
+	}
+
+	// Unencrypted objects could have been written by anyone with access to the bucket
+	if err := memory.Put(ctx, "work/plain.md", []byte("plain"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := store.Get(ctx, "work/plain.md"); !errors.Is(err, ErrPlaintext) {
+		t.Errorf("Expected the unencrypted object to be rejected, got %v", err)
+	}
+
+	// Unless objects uploaded before encryption was enabled are allowed
+	store.SetAllowPlaintext(true)
+	if body, _, _, err := store.Get(ctx, "work/plain.md"); err != nil || string(body) != "plain" {
+		t.Errorf("Expected 'plain', got %q (%v)", body, err)
+	}
+}
@@ -0,0 +1,326 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/options"
+)
+
+// Storage layouts of a vault in the bucket
+const (
+	// LayoutPaths stores every file under its path
+	LayoutPaths = options.LayoutPaths
+	// LayoutContent stores file contents once under their hash, with a manifest mapping paths to hashes
+	LayoutContent = options.LayoutContent
+)
+
+const (
+	manifestName   = "manifest.json"
+	blobsDir       = "blobs/"
+	manifestFormat = 1
+)
+
+// Manifest maps the paths of a vault to the hashes of their content, and keeps
+// earlier versions of each path as history. Blobs no longer referenced by any
+// path or version are kept as orphans for a while, so renames and reverts don't
+// upload the content again.
+type Manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]ManifestEntry `json:"files"`
+	// History lists the earlier versions of each path, oldest first
+	History map[string][]ManifestEntry `json:"history,omitempty"`
+	// Orphans are unreferenced blobs and when they became unreferenced
+	Orphans map[string]time.Time `json:"orphans,omitempty"`
+
+	// stored is the version of the manifest object this was read from, empty if there was none
+	stored string
+}
+
+// ManifestEntry describes one synced version of a file.
+type ManifestEntry struct {
+	Hash     string    `json:"hash,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Modified time.Time `json:"modified"`
+	// Deleted marks the version recording the file's deletion
+	Deleted bool `json:"deleted,omitempty"`
+	// Link marks a symlink synced as a link, whose blob holds its target
+	Link bool `json:"link,omitempty"`
+}
+
+// HistoryPolicy decides which earlier versions are kept: the latest Versions,
+// and all versions replaced within Retention. Nothing is kept when both are zero.
+type HistoryPolicy = options.HistoryPolicy
+
+func newManifest() *Manifest {
+	return &Manifest{
+		Version: manifestFormat,
+		Files:   make(map[string]ManifestEntry),
+		History: make(map[string][]ManifestEntry),
+		Orphans: make(map[string]time.Time),
+	}
+}
+
+// ManifestKey returns the key of the manifest of the vault stored under prefix.
+func ManifestKey(prefix string) string {
+	return prefix + manifestName
+}
+
+// BlobKey returns the key of the blob with the given content hash.
+func BlobKey(prefix, hash string) string {
+	return prefix + blobsDir + hash[:2] + "/" + hash
+}
+
+// LoadManifest reads the manifest of the vault stored under prefix, or returns
+// an empty one if the vault has not been uploaded yet.
+func LoadManifest(ctx context.Context, store ObjectStore, prefix string) (*Manifest, error) {
+	body, _, version, err := store.Get(ctx, ManifestKey(prefix))
+	if errors.Is(err, ErrNotFound) {
+		return newManifest(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %v", err)
+	}
+
+	m := newManifest()
+	m.stored = version
+	if err := json.Unmarshal(body, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", ManifestKey(prefix), err)
+	}
+	if m.Version > manifestFormat {
+		return nil, fmt.Errorf("manifest %s has format %d, this version supports up to %d", ManifestKey(prefix), m.Version, manifestFormat)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]ManifestEntry)
+	}
+	if m.History == nil {
+		m.History = make(map[string][]ManifestEntry)
+	}
+	if m.Orphans == nil {
+		m.Orphans = make(map[string]time.Time)
+	}
+	return m, nil
+}
+
+// Save writes the manifest under prefix, unless another writer saved it since
+// it was loaded, in which case it fails with an error wrapping ErrConflict.
+func (m *Manifest) Save(ctx context.Context, store ObjectStore, prefix string) error {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	version, err := store.PutIfMatch(ctx, ManifestKey(prefix), body, nil, m.stored)
+	if err != nil {
+		return fmt.Errorf("failed to save manifest: %w", err)
+	}
+	m.stored = version
+	return nil
+}
+
+// HasBlob reports whether a blob with the hash is stored, referenced or not.
+func (m *Manifest) HasBlob(hash string) bool {
+	if _, orphaned := m.Orphans[hash]; orphaned {
+		return true
+	}
+	return m.referenced(hash)
+}
+
+func (m *Manifest) referenced(hash string) bool {
+	for _, entry := range m.Files {
+		if entry.Hash == hash {
+			return true
+		}
+	}
+	for _, versions := range m.History {
+		for _, entry := range versions {
+			if entry.Hash == hash {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Set records the content of a file. The previous version moves to the history,
+// see Prune, and its blob is orphaned if nothing else uses it.
+func (m *Manifest) Set(path string, entry ManifestEntry, now time.Time) {
+	previous, existed := m.Files[path]
+	m.Files[path] = entry
+	delete(m.Orphans, entry.Hash)
+	if existed && previous.Hash != entry.Hash {
+		m.History[path] = append(m.History[path], previous)
+		m.release(previous.Hash, now)
+	}
+}
+
+// Remove forgets a file. The last version and the deletion move to the history,
+// see Prune, and its blob is orphaned if nothing else uses it.
+func (m *Manifest) Remove(path string, now time.Time) bool {
+	entry, existed := m.Files[path]
+	if !existed {
+		return false
+	}
+	delete(m.Files, path)
+	m.History[path] = append(m.History[path], entry, ManifestEntry{Modified: now, Deleted: true})
+	m.release(entry.Hash, now)
+	return true
+}
+
+func (m *Manifest) release(hash string, now time.Time) {
+	if hash != "" && !m.referenced(hash) {
+		m.Orphans[hash] = now
+	}
+}
+
+// Prune drops the versions the policy doesn't keep, orphaning their blobs.
+// A version counts as replaced when the version after it was synced; deletion
+// markers don't count as versions and go with the version they follow.
+func (m *Manifest) Prune(policy HistoryPolicy, now time.Time) {
+	var released []string
+	for path, versions := range m.History {
+		keep := make([]bool, len(versions))
+		count := 0
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i].Deleted {
+				continue
+			}
+			count++
+
+			// The last version was replaced by the current file or its deletion
+			replaced := now
+			if i+1 < len(versions) {
+				replaced = versions[i+1].Modified
+			} else if current, exists := m.Files[path]; exists {
+				replaced = current.Modified
+			}
+			keep[i] = count <= policy.Versions || (policy.Retention > 0 && now.Sub(replaced) <= policy.Retention)
+		}
+
+		var kept []ManifestEntry
+		for i, entry := range versions {
+			if entry.Deleted {
+				keep[i] = i > 0 && keep[i-1]
+			}
+			if keep[i] {
+				kept = append(kept, entry)
+			} else if entry.Hash != "" {
+				released = append(released, entry.Hash)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(m.History, path)
+		} else {
+			m.History[path] = kept
+		}
+	}
+
+	for _, hash := range released {
+		m.release(hash, now)
+	}
+}
+
+// Versions returns every known version of a path, oldest first, ending with the
+// current version or the deletion.
+func (m *Manifest) Versions(path string) []ManifestEntry {
+	versions := append([]ManifestEntry(nil), m.History[path]...)
+	if current, exists := m.Files[path]; exists {
+		versions = append(versions, current)
+	}
+	return versions
+}
+
+// At returns the version of a path that was current at t, if the file existed then.
+func (m *Manifest) At(path string, t time.Time) (ManifestEntry, bool) {
+	var found ManifestEntry
+	for _, entry := range m.Versions(path) {
+		if entry.Modified.After(t) {
+			break
+		}
+		found = entry
+	}
+	return found, found.Hash != "" && !found.Deleted
+}
+
+// ExpiredOrphans returns the orphaned blobs unreferenced since before cutoff.
+func (m *Manifest) ExpiredOrphans(cutoff time.Time) []string {
+	var hashes []string
+	for hash, since := range m.Orphans {
+		if since.Before(cutoff) {
+			hashes = append(hashes, hash)
+		}
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// RemoteFile is a file of a vault in the object store.
+type RemoteFile struct {
+	// Path is slash-separated and relative to the vault
+	Path string
+	Key  string
+	// Hash is the content hash of this version and Synced the hash of the
+	// latest synced version; both are only known with the content layout
+	Hash   string
+	Synced string
+	// Link marks a symlink whose object holds its target; with the paths
+	// layout it is only known from the object's metadata, see MetadataSymlink
+	Link bool
+}
+
+// ListFiles returns the files of the vault stored under prefix with the given
+// layout, as they were at the given time or currently when at is zero. Earlier
+// times need the content layout.
+func ListFiles(ctx context.Context, store ObjectStore, prefix, layout string, at time.Time) ([]RemoteFile, error) {
+	if layout == LayoutContent {
+		m, err := LoadManifest(ctx, store, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		paths := make(map[string]bool, len(m.Files))
+		for path := range m.Files {
+			paths[path] = true
+		}
+		if !at.IsZero() {
+			for path := range m.History {
+				paths[path] = true
+			}
+		}
+
+		files := make([]RemoteFile, 0, len(paths))
+		for path := range paths {
+			entry, exists := m.Files[path]
+			if !at.IsZero() {
+				entry, exists = m.At(path, at)
+			}
+			if !exists {
+				continue
+			}
+			files = append(files, RemoteFile{Path: path, Key: BlobKey(prefix, entry.Hash), Hash: entry.Hash, Synced: m.Files[path].Hash, Link: entry.Link})
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		return files, nil
+	}
+
+	if !at.IsZero() {
+		return nil, errors.New("earlier versions are only kept with the content layout")
+	}
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]RemoteFile, 0, len(keys))
+	for _, key := range keys {
+		if key == SettingsKey(prefix) {
+			continue
+		}
+		files = append(files, RemoteFile{Path: strings.TrimPrefix(key, prefix), Key: key})
+	}
+	return files, nil
+}
@@ -0,0 +1,84 @@
+package uploader
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2025, 6, 8, 12, 0, 0, 0, time.UTC)
+
+// edit records versions a, b, c... of note.md an hour apart
+func edit(m *Manifest, hashes ...string) time.Time {
+	now := start
+	for _, hash := range hashes {
+		m.Set("note.md", ManifestEntry{Hash: hash, Modified: now}, now)
+		now = now.Add(time.Hour)
+	}
+	return now
+}
+
+func TestPruneKeepsLatestVersions(t *testing.T) {
+	m := newManifest()
+	now := edit(m, "aa", "bb", "cc", "dd")
+
+	m.Prune(HistoryPolicy{Versions: 2}, now)
+
+	versions := m.Versions("note.md")
+	if len(versions) != 3 || versions[0].Hash != "bb" || versions[2].Hash != "dd" {
+		t.Errorf("Expected versions bb, cc, dd, got %v", versions)
+	}
+	if _, orphaned := m.Orphans["aa"]; !orphaned {
+		t.Error("Expected the pruned version's blob to be orphaned")
+	}
+	if _, orphaned := m.Orphans["bb"]; orphaned {
+		t.Error("Expected kept versions not to be orphaned")
+	}
+}
+
+func TestPruneKeepsVersionsWithinRetention(t *testing.T) {
+	m := newManifest()
+	now := edit(m, "aa", "bb", "cc", "dd")
+
+	// aa was replaced 3h ago, bb 2h ago and cc 1h ago
+	m.Prune(HistoryPolicy{Retention: 150 * time.Minute}, now)
+
+	if versions := m.Versions("note.md"); len(versions) != 3 || versions[0].Hash != "bb" {
+		t.Errorf("Expected versions bb, cc, dd, got %v", versions)
+	}
+
+	// Without a policy no history is kept
+	m.Prune(HistoryPolicy{}, now)
+	if versions := m.Versions("note.md"); len(versions) != 1 {
+		t.Errorf("Expected only the current version, got %v", versions)
+	}
+}
+
+func TestVersionAtTime(t *testing.T) {
+	m := newManifest()
+	now := edit(m, "aa", "bb")
+	m.Remove("note.md", now)
+
+	tests := []struct {
+		at     time.Time
+		hash   string
+		exists bool
+	}{
+		{start.Add(-time.Minute), "", false},
+		{start.Add(30 * time.Minute), "aa", true},
+		{start.Add(time.Hour), "bb", true},
+		{now.Add(time.Minute), "", false},
+	}
+	for i, tt := range tests {
+		entry, exists := m.At("note.md", tt.at)
+		if exists != tt.exists || (exists && entry.Hash != tt.hash) {
+			t.Errorf("Test %d: expected %q (%v), got %q (%v)", i, tt.hash, tt.exists, entry.Hash, exists)
+		}
+	}
+
+	// The deletion marker goes with the version before it
+	m.Prune(HistoryPolicy{Versions: 1}, now)
+	versions := m.Versions("note.md")
+	if len(versions) != 2 || versions[0].Hash != "bb" || !versions[1].Deleted {
+		t.Errorf("Expected bb and its deletion, got %v", versions)
+	}
+}
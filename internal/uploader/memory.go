@@ -0,0 +1,96 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is an in-process ObjectStore, used in tests and by RecordingStore.
+type MemoryStore struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+	// writes numbers the versions of the objects
+	writes int
+}
+
+type memoryObject struct {
+	body     []byte
+	metadata map[string]string
+	version  string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+func (m *MemoryStore) Put(ctx context.Context, key string, body []byte, metadata map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.put(key, body, metadata)
+	return nil
+}
+
+func (m *MemoryStore) PutIfMatch(ctx context.Context, key string, body []byte, metadata map[string]string, version string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.objects[key].version != version {
+		return "", fmt.Errorf("%s: %w", key, ErrConflict)
+	}
+	return m.put(key, body, metadata), nil
+}
+
+func (m *MemoryStore) put(key string, body []byte, metadata map[string]string) string {
+	m.writes++
+	version := strconv.Itoa(m.writes)
+	m.objects[key] = memoryObject{body: clone(body), metadata: cloneMetadata(metadata), version: version}
+	return version
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) ([]byte, map[string]string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	object, exists := m.objects[key]
+	if !exists {
+		return nil, nil, "", fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return clone(object.body), cloneMetadata(object.metadata), object.version, nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *MemoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+func cloneMetadata(metadata map[string]string) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		copied[k] = v
+	}
+	return copied
+}
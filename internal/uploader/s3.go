@@ -0,0 +1,177 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Store is an ObjectStore backed by an S3 bucket.
+type S3Store struct {
+	client      *s3.Client
+	bucket      string
+	credentials *credentials
+}
+
+// NewS3Store creates a store for the given bucket, resolving credentials
+// through the default AWS credential chain until SetCredentials is called.
+func NewS3Store(ctx context.Context, bucket, region string) (*S3Store, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+
+	creds := &credentials{fallback: awsCfg.Credentials}
+	awsCfg.Credentials = creds
+
+	return &S3Store{
+		client:      s3.NewFromConfig(awsCfg),
+		bucket:      bucket,
+		credentials: creds,
+	}, nil
+}
+
+// SetCredentials switches to static access keys, taking effect on the next request.
+// Empty keys fall back to the default AWS credential chain.
+func (s *S3Store) SetCredentials(accessKeyID, secretAccessKey string) {
+	s.credentials.set(accessKeyID, secretAccessKey)
+}
+
+// credentials is an aws.CredentialsProvider whose static keys can be swapped at runtime
+type credentials struct {
+	mu       sync.RWMutex
+	static   *aws.Credentials
+	fallback aws.CredentialsProvider
+}
+
+func (c *credentials) set(accessKeyID, secretAccessKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if accessKeyID == "" || secretAccessKey == "" {
+		c.static = nil
+		return
+	}
+	c.static = &aws.Credentials{
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Source:          "obsidian-sync config",
+	}
+}
+
+func (c *credentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	c.mu.RLock()
+	static := c.static
+	c.mu.RUnlock()
+
+	if static != nil {
+		return *static, nil
+	}
+	return c.fallback.Retrieve(ctx)
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, metadata map[string]string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Body:     bytes.NewReader(body),
+		Metadata: metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %v", s.bucket, key, err)
+	}
+	return nil
+}
+
+// PutIfMatch uploads with an If-Match precondition on the object's ETag, or If-None-Match
+// when the object must not exist yet.
+func (s *S3Store) PutIfMatch(ctx context.Context, key string, body []byte, metadata map[string]string, version string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Body:     bytes.NewReader(body),
+		Metadata: metadata,
+	}
+	if version == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(version)
+	}
+
+	out, err := s.client.PutObject(ctx, input)
+	if isConflict(err) {
+		return "", fmt.Errorf("s3://%s/%s: %w", s.bucket, key, ErrConflict)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to upload s3://%s/%s: %v", s.bucket, key, err)
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// isConflict reports whether a conditional request failed because the object changed:
+// S3 answers PreconditionFailed, or ConditionalRequestConflict when racing another write
+func isConflict(err error) bool {
+	var apiErr interface{ ErrorCode() string }
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict"
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete s3://%s/%s: %v", s.bucket, key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, map[string]string, string, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, nil, "", fmt.Errorf("s3://%s/%s: %w", s.bucket, key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to download s3://%s/%s: %v", s.bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to download s3://%s/%s: %v", s.bucket, key, err)
+	}
+	return body, out.Metadata, aws.ToString(out.ETag), nil
+}
+
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %v", s.bucket, prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,127 @@
+package uploader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/internal/obsidian"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// SettingsKey is where the settings bundle of the vault under prefix is stored
+func SettingsKey(prefix string) string {
+	return prefix + obsidian.ConfigDir + ".zip"
+}
+
+// SettingsBundle uploads a vault's settings, the synced files of its .obsidian
+// folder, as one zip archive whenever one of them changes.
+type SettingsBundle struct {
+	store     ObjectStore
+	vaultPath string
+	prefix    string
+
+	// mu guards the hash of the last uploaded bundle
+	mu   sync.Mutex
+	last string
+}
+
+func NewSettingsBundle(store ObjectStore, vaultPath, prefix string) *SettingsBundle {
+	return &SettingsBundle{
+		store:     store,
+		vaultPath: vaultPath,
+		prefix:    prefix,
+	}
+}
+
+// Name implements pipeline.Sink
+func (b *SettingsBundle) Name() string {
+	return "settings"
+}
+
+// Send uploads the bundle again unless its content is unchanged. Every event
+// leads to the whole folder being bundled, so deletions are picked up too.
+func (b *SettingsBundle) Send(ctx context.Context, event models.FileEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bundle, err := Bundle(b.vaultPath)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(bundle)
+	hash := hex.EncodeToString(sum[:])
+	if hash == b.last {
+		log.DebugWithFields("⏭️ Settings unchanged, nothing to upload", logger.Fields{"path": event.FilePath, "sink": b.Name()})
+		return nil
+	}
+
+	key := SettingsKey(b.prefix)
+	if err := b.store.Put(ctx, key, bundle, nil); err != nil {
+		return err
+	}
+	b.last = hash
+
+	metrics.UploadedBytes.WithLabelValues(b.Name()).Add(float64(len(bundle)))
+	log.DebugWithFields("☁️  Uploaded settings", logger.Fields{"key": key, "bytes": len(bundle), "sink": b.Name()})
+	return nil
+}
+
+// Bundle zips the settings of the vault. Files are added in path order without
+// timestamps, so the same settings always give the same archive.
+func Bundle(vaultPath string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	root := filepath.Join(vaultPath, obsidian.ConfigDir)
+	// WalkDir visits files in lexical order
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(vaultPath, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if !obsidian.InConfigDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !obsidian.IsSetting(rel) {
+			return nil
+		}
+
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: filepath.ToSlash(rel), Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
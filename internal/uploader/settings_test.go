@@ -0,0 +1,71 @@
+package uploader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestSettingsBundle(t *testing.T) {
+	vault := t.TempDir()
+	files := map[string]string{
+		".obsidian/app.json":                   `{"vimMode": true}`,
+		".obsidian/workspace.json":             `{"main": {}}`,
+		".obsidian/snippets/wide.css":          `.markdown { max-width: 100%; }`,
+		".obsidian/plugins/dataview/.git/HEAD": "ref: refs/heads/main",
+		"note.md":                              "# Note",
+	}
+	for name, content := range files {
+		path := filepath.Join(vault, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	store := NewMemoryStore()
+	sink := NewSettingsBundle(store, vault, "work/")
+	event := models.FileEvent{EventType: models.EventModified, FilePath: filepath.Join(vault, ".obsidian", "app.json")}
+	if err := sink.Send(ctx, event); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	body, _, _, err := store.Get(ctx, SettingsKey("work/"))
+	if err != nil {
+		t.Fatalf("Expected the bundle to be uploaded, got %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 2 || names[0] != ".obsidian/app.json" || names[1] != ".obsidian/snippets/wide.css" {
+		t.Errorf("Expected app.json and the snippet, got %v", names)
+	}
+
+	// The same settings give the same bundle, even after the files were touched
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(event.FilePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Bundle(vault); err != nil || !bytes.Equal(again, body) {
+		t.Errorf("Expected an identical bundle, got a different one (%v)", err)
+	}
+
+	// The bundle is not a vault file to restore
+	if files, err := ListFiles(ctx, store, "work/", LayoutPaths, time.Time{}); err != nil || len(files) != 0 {
+		t.Errorf("Expected no files to restore, got %v (%v)", files, err)
+	}
+}
@@ -0,0 +1,125 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// log is the uploader component logger
+var log = logger.Component("uploader")
+
+// ErrNotFound is returned by ObjectStore.Get for missing objects
+var ErrNotFound = errors.New("object not found")
+
+// ErrConflict is returned by ObjectStore.PutIfMatch when the object was written since it was read
+var ErrConflict = errors.New("object changed since it was read")
+
+// MetadataSymlink marks objects of the paths layout holding a symlink's target
+// instead of file content, see models.FileEvent.LinkTarget
+const MetadataSymlink = "symlink"
+
+// ObjectStore is the subset of object storage operations the uploader and restores rely on.
+type ObjectStore interface {
+	// Put stores body under key along with optional metadata
+	Put(ctx context.Context, key string, body []byte, metadata map[string]string) error
+	// PutIfMatch is Put for objects shared with other writers: it only stores body if the
+	// object is still at version, as returned by Get, or doesn't exist when version is
+	// empty, and fails with an error wrapping ErrConflict otherwise. It returns the new version.
+	PutIfMatch(ctx context.Context, key string, body []byte, metadata map[string]string, version string) (string, error)
+	// Get returns an object, its metadata and its version, an opaque tag that
+	// changes with every write, or an error wrapping ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, map[string]string, string, error)
+	Delete(ctx context.Context, key string) error
+	// List returns the keys starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Uploader mirrors vault files into an object store, keyed by their path relative
+// to the vault behind an optional prefix, e.g. "work/" when several vaults share a bucket.
+type Uploader struct {
+	store     ObjectStore
+	vaultPath string
+	prefix    string
+}
+
+func New(store ObjectStore, vaultPath, prefix string) *Uploader {
+	return &Uploader{
+		store:     store,
+		vaultPath: vaultPath,
+		prefix:    prefix,
+	}
+}
+
+// Name implements pipeline.Sink
+func (u *Uploader) Name() string {
+	return "s3"
+}
+
+// Send uploads created or modified files and removes deleted ones.
+func (u *Uploader) Send(ctx context.Context, event models.FileEvent) error {
+	key, err := u.objectKey(event.FilePath)
+	if err != nil {
+		return err
+	}
+
+	if event.EventType == models.EventDeleted {
+		return u.store.Delete(ctx, key)
+	}
+
+	body, err := readContent(event)
+	if errors.Is(err, os.ErrNotExist) {
+		// The file disappeared before we got to it; its delete event will follow
+		log.DebugWithFields("🤷 Skipping upload of vanished file", logger.Fields{"path": event.FilePath, "sink": u.Name()})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", event.FilePath, err)
+	}
+
+	var metadata map[string]string
+	if event.LinkTarget != "" {
+		metadata = map[string]string{MetadataSymlink: "true"}
+	}
+	if err := u.store.Put(ctx, key, body, metadata); err != nil {
+		return err
+	}
+
+	metrics.UploadedBytes.WithLabelValues(u.Name()).Add(float64(len(body)))
+	log.DebugWithFields("☁️  Uploaded file", logger.Fields{"path": event.FilePath, "key": key, "bytes": len(body), "sink": u.Name()})
+	return nil
+}
+
+// readContent returns what is uploaded for an event's file: its content, or the
+// current target of a symlink synced as a link
+func readContent(event models.FileEvent) ([]byte, error) {
+	if event.LinkTarget != "" {
+		target, err := os.Readlink(event.FilePath)
+		return []byte(target), err
+	}
+	return os.ReadFile(event.FilePath)
+}
+
+// objectKey converts an absolute file path into its key behind the prefix
+func (u *Uploader) objectKey(path string) (string, error) {
+	rel, err := relativeKey(u.vaultPath, path)
+	if err != nil {
+		return "", err
+	}
+	return u.prefix + rel, nil
+}
+
+// relativeKey converts an absolute file path into a slash-separated path relative to the vault
+func relativeKey(vaultPath, path string) (string, error) {
+	rel, err := filepath.Rel(vaultPath, path)
+	if err != nil {
+		return "", fmt.Errorf("file %s is not inside vault %s: %v", path, vaultPath, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,23 @@
+package watcher
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxUserWatchesPath holds the inotify watch limit shared by all processes of a user
+const maxUserWatchesPath = "/proc/sys/fs/inotify/max_user_watches"
+
+// watchLimit returns the kernel's limit on watched directories, if it has one
+func watchLimit() (int, bool) {
+	data, err := os.ReadFile(maxUserWatchesPath)
+	if err != nil {
+		return 0, false
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, false
+	}
+	return limit, true
+}
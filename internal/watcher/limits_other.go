@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watcher
+
+// watchLimit returns the kernel's limit on watched directories, if it has one
+func watchLimit() (int, bool) {
+	return 0, false
+}
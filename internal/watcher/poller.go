@@ -0,0 +1,126 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+)
+
+// Poller monitors a vault by scanning it for changed files every interval. It
+// works where native file events don't, such as network filesystems, FUSE mounts
+// and some container bind mounts, at the cost of noticing changes later.
+type Poller struct {
+	*vault
+	interval time.Duration
+	done     chan bool
+
+	// lifecycleMu guards stopped, so Stop is safe while Start is retried
+	lifecycleMu sync.Mutex
+	stopped     bool
+
+	// mu guards files, the state of the last scan, and keeps scans from overlapping
+	mu    sync.Mutex
+	files map[string]fileState
+}
+
+// NewPoller creates a poller scanning the vault at path every interval.
+func NewPoller(path string, interval time.Duration) *Poller {
+	return newPoller(newVault(path), interval)
+}
+
+func newPoller(v *vault, interval time.Duration) *Poller {
+	return &Poller{
+		vault:    v,
+		interval: interval,
+		done:     make(chan bool),
+	}
+}
+
+// Start scans the vault for its current files, then scans it again every interval,
+// emitting events for the files created, modified or deleted in between.
+// It blocks until Stop is called.
+//
+// Returns an error if the vault path is not an accessible directory or can't be
+// scanned. Start can be called again after an error. It returns nil without
+// polling if Stop was already called.
+func (p *Poller) Start() error {
+	p.lifecycleMu.Lock()
+	if p.stopped {
+		p.lifecycleMu.Unlock()
+		return nil
+	}
+	if err := p.checkDir(); err != nil {
+		p.lifecycleMu.Unlock()
+		return err
+	}
+
+	p.mu.Lock()
+	files, err := p.scan(p.path, p.files)
+	if err == nil {
+		p.files = files
+	}
+	p.mu.Unlock()
+	p.lifecycleMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	log.InfoWithFields("🔍 Polling vault for changes", p.fields(p.path, logger.Fields{"files": len(files), "interval": p.interval}))
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return nil
+		case <-ticker.C:
+			p.poll(false)
+		}
+	}
+}
+
+// Flush scans the vault immediately, emitting the changes since the last scan
+// including files still being written.
+func (p *Poller) Flush() {
+	p.poll(true)
+}
+
+func (p *Poller) Stop() error {
+	p.lifecycleMu.Lock()
+	if p.stopped {
+		p.lifecycleMu.Unlock()
+		return nil
+	}
+	p.stopped = true
+	p.lifecycleMu.Unlock()
+
+	p.Flush()
+	close(p.done)
+	return nil
+}
+
+// poll scans the vault and emits an event for every file created, modified or
+// deleted since the last scan, see compare.
+func (p *Poller) poll(all bool) {
+	p.mu.Lock()
+	if p.files == nil {
+		// Start hasn't scanned the vault yet
+		p.mu.Unlock()
+		return
+	}
+
+	scanned, err := p.scan(p.path, p.files)
+	if err != nil {
+		p.mu.Unlock()
+		log.WarnWithFields("⚠️ Failed to scan vault", p.fields(p.path, logger.Fields{"error": err}))
+		return
+	}
+	events := p.compare(p.files, scanned, p.path, all)
+	p.mu.Unlock()
+
+	// Dispatch outside the lock so slow handlers don't delay the next scan
+	for _, event := range events {
+		p.emit(event)
+	}
+}
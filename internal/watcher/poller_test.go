@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+func TestPollerEmitsChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	kept := filepath.Join(tmpDir, "kept.md")
+	removed := filepath.Join(tmpDir, "removed.md")
+	for _, file := range []string{kept, removed} {
+		if err := os.WriteFile(file, []byte("# Note"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := NewPoller(tmpDir, 20*time.Millisecond)
+	p.SetDebounce(10 * time.Millisecond)
+	events := make(chan models.FileEvent, 10)
+	p.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+
+	go func() {
+		if err := p.Start(); err != nil {
+			t.Errorf("Failed to start poller: %v", err)
+		}
+	}()
+	defer p.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	created := filepath.Join(tmpDir, "daily", "2025-06-08.md")
+	if err := os.MkdirAll(filepath.Dir(created), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(created, []byte("# Today"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kept, []byte("# Note, edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(removed); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]models.EventType{
+		created: models.EventCreated,
+		kept:    models.EventModified,
+		removed: models.EventDeleted,
+	}
+	for len(expected) > 0 {
+		select {
+		case event := <-events:
+			want, ok := expected[event.FilePath]
+			if !ok || event.EventType != want {
+				t.Errorf("Unexpected %s event for %s", event.EventType, event.FilePath)
+			}
+			delete(expected, event.FilePath)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for events, missing %v", expected)
+		}
+	}
+}
+
+func TestPollerKeepsFilesWhenVaultIsUnavailable(t *testing.T) {
+	tmpDir := t.TempDir()
+	vaultPath := filepath.Join(tmpDir, "vault")
+	if err := os.Mkdir(vaultPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vaultPath, "note.md"), []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPoller(vaultPath, time.Hour)
+	var events []models.FileEvent
+	p.OnEvent(func(event models.FileEvent) {
+		events = append(events, event)
+	})
+	go p.Start()
+	defer p.Stop()
+	time.Sleep(50 * time.Millisecond)
+
+	// An unmounted share looks like a missing vault, not like deleted notes
+	if err := os.Rename(vaultPath, filepath.Join(tmpDir, "unmounted")); err != nil {
+		t.Fatal(err)
+	}
+	p.Flush()
+	if len(events) != 0 {
+		t.Errorf("Expected no events while the vault is unavailable, got %v", events)
+	}
+
+	if err := os.Rename(filepath.Join(tmpDir, "unmounted"), vaultPath); err != nil {
+		t.Fatal(err)
+	}
+	p.Flush()
+	if len(events) != 0 {
+		t.Errorf("Expected no events once the vault is back, got %v", events)
+	}
+}
+
+func TestReconcileEmitsChangesMissingRemotely(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{"same.md": "# Same", "edited.md": "# Edited", "new.md": "# New"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	same := sha256.Sum256([]byte("# Same"))
+
+	p := NewPoller(tmpDir, time.Hour)
+	var events []models.FileEvent
+	p.OnEvent(func(event models.FileEvent) {
+		events = append(events, event)
+	})
+
+	count, err := p.Reconcile(map[string]string{
+		"same.md":   hex.EncodeToString(same[:]),
+		"edited.md": "outdated",
+		"gone.md":   "removed locally",
+	})
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if count != 2 || len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].FilePath != filepath.Join(tmpDir, "edited.md") || events[0].EventType != models.EventModified {
+		t.Errorf("Expected edited.md to be modified, got %s %s", events[0].EventType, events[0].FilePath)
+	}
+	if events[1].FilePath != filepath.Join(tmpDir, "new.md") || events[1].EventType != models.EventCreated {
+		t.Errorf("Expected new.md to be created, got %s %s", events[1].EventType, events[1].FilePath)
+	}
+}
@@ -0,0 +1,176 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// fileState is what a scan compares to notice that a file changed
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// stat returns the state of a file, if it exists, or of the link itself when
+// syncing symlinks as links
+func (v *vault) stat(path string) (fileState, bool) {
+	stat := os.Stat
+	if v.symlinkPolicy() == SymlinkLink {
+		stat = os.Lstat
+	}
+	info, err := stat(path)
+	if err != nil || info.IsDir() {
+		return fileState{}, false
+	}
+	return fileState{size: info.Size(), modTime: info.ModTime()}, true
+}
+
+// within reports whether path is root or below it
+func within(path, root string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// scan returns the state of every synced file under root, a folder of the vault.
+// It fails when the vault itself can't be read, so an unmounted share isn't taken
+// for a vault whose files were all deleted; a missing folder below it has no files.
+// The files of a folder that can't be read keep their state in known.
+func (v *vault) scan(root string, known map[string]fileState) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	var unreadable []string
+	err := v.walk(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if path == v.path {
+				return err
+			}
+			if path == root && errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			log.WarnWithFields("⚠️ Error accessing path", v.fields(path, logger.Fields{"error": err}))
+			unreadable = append(unreadable, path)
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != v.path && !v.isWatchedDirectory(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !v.isVaultFile(path) || v.isIgnored(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Deleted since the folder was read
+			return nil
+		}
+		files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for path, state := range known {
+		for _, dir := range unreadable {
+			if within(path, dir) {
+				files[path] = state
+			}
+		}
+	}
+	return files, nil
+}
+
+// compare updates known to the files scanned under root and returns an event for
+// every file created, modified or deleted in between. A file modified within the
+// debounce interval may still be being written, so unless all is set its change
+// is left for the next scan.
+func (v *vault) compare(known, scanned map[string]fileState, root string, all bool) []models.FileEvent {
+	v.settingsMu.RLock()
+	quiet := time.Now().Add(-v.debounce)
+	v.settingsMu.RUnlock()
+
+	var events []models.FileEvent
+	for path, state := range scanned {
+		previous, existed := known[path]
+		if existed && previous.equal(state) {
+			continue
+		}
+		if !all && state.modTime.After(quiet) {
+			continue
+		}
+		known[path] = state
+
+		if existed {
+			log.InfoWithFields("✏️  File modified", v.fields(path, nil))
+			events = append(events, v.newEvent(path, models.EventModified))
+		} else {
+			log.InfoWithFields("✅ File created", v.fields(path, nil))
+			events = append(events, v.newEvent(path, models.EventCreated))
+		}
+	}
+	for path := range known {
+		if _, exists := scanned[path]; !exists && within(path, root) {
+			delete(known, path)
+			log.InfoWithFields("🗑️  File deleted", v.fields(path, nil))
+			events = append(events, v.newEvent(path, models.EventDeleted))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].FilePath < events[j].FilePath })
+	return events
+}
+
+// Reconcile compares the vault with the files stored remotely, given by their
+// slash-separated path relative to the vault and content hash, empty when not
+// known. It emits a created event for every file missing remotely and a modified
+// event for every file whose content differs, and returns the number emitted.
+// Files only stored remotely are left alone.
+func (v *vault) Reconcile(stored map[string]string) (int, error) {
+	files, err := v.scan(v.path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("cannot reconcile the vault: %v", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	count := 0
+	for _, path := range paths {
+		rel, err := filepath.Rel(v.path, path)
+		if err != nil {
+			continue
+		}
+		hash, exists := stored[filepath.ToSlash(rel)]
+		if !exists {
+			v.emit(v.newEvent(path, models.EventCreated))
+			count++
+			continue
+		}
+		if hash == "" {
+			continue
+		}
+		if event := v.newEvent(path, models.EventModified); event.Checksum != hash {
+			v.emit(event)
+			count++
+		}
+	}
+
+	log.InfoWithFields("🔄 Reconciled vault", v.fields(v.path, logger.Fields{"count": count}))
+	return count, nil
+}
@@ -0,0 +1,357 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarangop/obsidian-sync/internal/canvas"
+	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/internal/obsidian"
+	"github.com/aarangop/obsidian-sync/internal/options"
+	"github.com/aarangop/obsidian-sync/pkg/models"
+)
+
+// FileWatcher reports the changes to the files of a vault, either from native
+// file events (Watcher) or by scanning the vault (Poller).
+type FileWatcher interface {
+	SetVaultID(id string)
+	SetSyncSettings(enabled bool)
+	SetDebounce(d time.Duration)
+	SetIgnorePatterns(patterns []string)
+	SetSymlinks(policy string)
+	OnEvent(handler EventHandler)
+	// Start blocks until Stop is called
+	Start() error
+	Stop() error
+	Flush()
+	Resync(path string) (int, error)
+	Reconcile(stored map[string]string) (int, error)
+	Syncs(rel string) bool
+}
+
+// Modes select the FileWatcher of a vault
+const (
+	// ModeAuto uses native file events, polling when the directories can't be watched
+	ModeAuto = options.WatchAuto
+	// ModeNative only uses native file events
+	ModeNative = options.WatchNative
+	// ModePoll scans the vault for changes
+	ModePoll = options.WatchPoll
+)
+
+// Symlink policies decide how the symlinks in a vault are synced
+const (
+	// SymlinkIgnore skips symlinked files and folders
+	SymlinkIgnore = options.SymlinkIgnore
+	// SymlinkFollow syncs what symlinks point to as if it were in the vault
+	SymlinkFollow = options.SymlinkFollow
+	// SymlinkLink syncs the links themselves, with their target instead of content
+	SymlinkLink = options.SymlinkLink
+)
+
+// EventHandler receives the debounced file events produced by the watcher.
+type EventHandler func(event models.FileEvent)
+
+// defaultDebounce is how long a file must be quiet before its events are processed
+const defaultDebounce = 100 * time.Millisecond
+
+// vault holds what the watcher implementations share: which files of the vault
+// are synced, and how their events are built and handed to the handlers.
+type vault struct {
+	path     string
+	vaultID  string
+	handlers []EventHandler
+
+	// syncSettings also watches the files of the config folder, see SetSyncSettings
+	syncSettings bool
+	// symlinks is the symlink policy, see SetSymlinks
+	symlinks string
+
+	// settingsMu guards the settings that can change at runtime
+	settingsMu     sync.RWMutex
+	debounce       time.Duration
+	ignorePatterns []string
+}
+
+func newVault(path string) *vault {
+	return &vault{path: path, debounce: defaultDebounce, symlinks: SymlinkFollow}
+}
+
+// checkDir fails unless the vault path is an accessible directory
+func (v *vault) checkDir() error {
+	info, err := os.Stat(v.path)
+	if err != nil {
+		return fmt.Errorf("cannot access vault path: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("vault path is not a directory: %s", v.path)
+	}
+	return nil
+}
+
+// SetVaultID sets the vault ID stamped on every event. Call it before Start.
+func (v *vault) SetVaultID(id string) {
+	v.vaultID = id
+}
+
+// SetSyncSettings makes the watcher also report changes to the vault's settings
+// in the .obsidian folder, except the workspace layout. Call it before Start.
+func (v *vault) SetSyncSettings(enabled bool) {
+	v.syncSettings = enabled
+}
+
+// SetSymlinks sets the symlink policy, one of the Symlink constants; symlinks are
+// followed by default. Call it before Start.
+func (v *vault) SetSymlinks(policy string) {
+	v.symlinks = policy
+}
+
+func (v *vault) symlinkPolicy() string {
+	return v.symlinks
+}
+
+// SetDebounce changes how long the watcher waits for quiet before processing events.
+func (v *vault) SetDebounce(d time.Duration) {
+	v.settingsMu.Lock()
+	defer v.settingsMu.Unlock()
+	v.debounce = d
+}
+
+// SetIgnorePatterns replaces the glob patterns of paths to ignore. Patterns are
+// matched against each path component and against the path relative to the vault,
+// so "templates" ignores a whole folder and "*.excalidraw.md" a kind of file.
+func (v *vault) SetIgnorePatterns(patterns []string) {
+	v.settingsMu.Lock()
+	defer v.settingsMu.Unlock()
+	v.ignorePatterns = patterns
+}
+
+// OnEvent registers a handler that is called for every debounced file event.
+// Handlers must be registered before calling Start.
+func (v *vault) OnEvent(handler EventHandler) {
+	v.handlers = append(v.handlers, handler)
+}
+
+// newEvent builds the event payload for a path, including size and checksum for files that still exist
+func (v *vault) newEvent(path string, eventType models.EventType) models.FileEvent {
+	event := models.FileEvent{
+		EventType: eventType,
+		FilePath:  path,
+		VaultPath: v.path,
+		VaultID:   v.vaultID,
+		Timestamp: time.Now().UTC(),
+	}
+
+	if eventType == models.EventDeleted {
+		return event
+	}
+
+	if v.symlinkPolicy() == SymlinkLink && isSymlink(path) {
+		target, err := os.Readlink(path)
+		if err != nil {
+			log.WarnWithFields("⚠️ Failed to read symlink", v.fields(path, logger.Fields{"error": err}))
+			return event
+		}
+		sum := sha256.Sum256([]byte(target))
+		event.LinkTarget = target
+		event.FileSize = int64(len(target))
+		event.Checksum = hex.EncodeToString(sum[:])
+		return event
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.WarnWithFields("⚠️ Failed to read file for checksum", v.fields(path, logger.Fields{"error": err}))
+		return event
+	}
+
+	sum := sha256.Sum256(data)
+	event.FileSize = int64(len(data))
+	event.Checksum = hex.EncodeToString(sum[:])
+
+	if canvas.IsCanvas(path) {
+		// A board that doesn't parse is still synced, only without its structure
+		if event.Canvas, err = canvas.Parse(data); err != nil {
+			log.WarnWithFields("⚠️ Failed to parse canvas", v.fields(path, logger.Fields{"error": err}))
+		}
+	}
+	return event
+}
+
+func (v *vault) emit(event models.FileEvent) {
+	metrics.Events.WithLabelValues(string(event.EventType)).Inc()
+	for _, handler := range v.handlers {
+		v.callHandler(handler, event)
+	}
+}
+
+// callHandler runs a handler, recovering from panics so one failing handler
+// doesn't take down the watcher or the other handlers
+func (v *vault) callHandler(handler EventHandler, event models.FileEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.ErrorWithFields("⚠️ Event handler panicked", v.fields(event.FilePath, logger.Fields{"panic": r}))
+		}
+	}()
+	handler(event)
+}
+
+// Resync emits a modified event for every note and canvas board under path, which is
+// relative to the vault root; an empty path resyncs the whole vault.
+// It returns the number of events emitted.
+func (v *vault) Resync(path string) (int, error) {
+	// Cleaning against "/" keeps the path from escaping the vault
+	root := filepath.Join(v.path, filepath.Clean("/"+path))
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return 0, fmt.Errorf("cannot resync %s: %v", path, err)
+	}
+
+	if v.isIgnored(root) {
+		return 0, fmt.Errorf("cannot resync %s: path is ignored", path)
+	}
+
+	if !info.IsDir() {
+		if !v.isVaultFile(root) {
+			return 0, fmt.Errorf("cannot resync %s: not a note or canvas", path)
+		}
+		v.emit(v.newEvent(root, models.EventModified))
+		return 1, nil
+	}
+
+	count := 0
+	err = v.walk(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			log.WarnWithFields("⚠️ Error accessing path", v.fields(path, logger.Fields{"error": err}))
+			return nil
+		}
+
+		if d.IsDir() {
+			if path != root && !v.isWatchedDirectory(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if v.isVaultFile(path) && !v.isIgnored(path) {
+			v.emit(v.newEvent(path, models.EventModified))
+			count++
+		}
+		return nil
+	})
+
+	log.InfoWithFields("🔄 Resynced files", v.fields(root, logger.Fields{"count": count}))
+	return count, err
+}
+
+// fields returns the log fields for a path in this vault, merged with extra
+func (v *vault) fields(path string, extra logger.Fields) logger.Fields {
+	fields := logger.Fields{"path": path}
+	if v.vaultID != "" {
+		fields["vault"] = v.vaultID
+	}
+	for key, value := range extra {
+		fields[key] = value
+	}
+	return fields
+}
+
+// isVaultFile reports whether a file is synced: a note or canvas board outside
+// hidden folders, or a setting when syncing settings. A note moved into .trash
+// is no longer a vault file, so the move is a deletion.
+func (v *vault) isVaultFile(filename string) bool {
+	rel, err := filepath.Rel(v.path, filename)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	base := filepath.Base(filename)
+	if obsidian.IsJunk(base) || obsidian.IsSyncConflict(base) {
+		return false
+	}
+	if obsidian.IsSetting(rel) {
+		return v.syncSettings
+	}
+	if obsidian.IsHidden(rel) || strings.HasPrefix(base, "~") {
+		return false
+	}
+	if v.symlinkPolicy() != SymlinkFollow && isSymlink(filename) {
+		// A linked folder is synced as a link too
+		return v.symlinkPolicy() == SymlinkLink
+	}
+
+	return filepath.Ext(filename) == ".md" || canvas.IsCanvas(filename)
+}
+
+// Syncs reports whether the file at rel, a slash-separated path relative to the
+// vault, is synced as a note or canvas board. Hidden, junk, ignored and settings
+// files are not, the settings being synced as a bundle.
+func (v *vault) Syncs(rel string) bool {
+	if obsidian.IsSetting(rel) || obsidian.InConfigDir(rel) {
+		return false
+	}
+	path := filepath.Join(v.path, filepath.FromSlash(rel))
+	return v.isVaultFile(path) && !v.isIgnored(path)
+}
+
+// isWatchedDirectory reports whether a directory below the vault root is watched: hidden
+// ones are skipped, except the config folder when syncing settings, as are ignored ones
+func (v *vault) isWatchedDirectory(path string) bool {
+	if v.isIgnored(path) {
+		return false
+	}
+	rel, err := filepath.Rel(v.path, path)
+	if err != nil {
+		return false
+	}
+	return !obsidian.IsHidden(rel) || (v.syncSettings && obsidian.InConfigDir(rel))
+}
+
+// isIgnored reports whether the path or one of its parent folders matches an ignore pattern
+func (v *vault) isIgnored(filename string) bool {
+	v.settingsMu.RLock()
+	patterns := v.ignorePatterns
+	v.settingsMu.RUnlock()
+
+	if len(patterns) == 0 {
+		return false
+	}
+
+	rel, err := filepath.Rel(v.path, filename)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		prefix := filepath.Join(parts[:i+1]...)
+		for _, pattern := range patterns {
+			pattern = filepath.FromSlash(pattern)
+			if matched, _ := filepath.Match(pattern, part); matched {
+				return true
+			}
+			if matched, _ := filepath.Match(pattern, prefix); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDirectory reports whether filename is a folder, or a symlink to one that is followed
+func (v *vault) isDirectory(filename string) bool {
+	stat := os.Lstat
+	if v.symlinkPolicy() == SymlinkFollow {
+		stat = os.Stat
+	}
+	info, err := stat(filename)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,102 @@
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walk calls fn for root and the files and folders below it in lexical order, like
+// filepath.WalkDir, handling symlinks by the symlink policy: ignored links are
+// skipped, followed ones are walked as the file or folder they point to, and
+// otherwise fn gets the link itself. When following, each folder is walked once
+// however many links lead to it, so a link to one of its parents doesn't loop.
+func (v *vault) walk(root string, fn fs.WalkDirFunc) error {
+	// The vault itself may be a link, which is always followed
+	stat := os.Lstat
+	if root == v.path {
+		stat = os.Stat
+	}
+	info, err := stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = v.walkEntry(root, fs.FileInfoToDirEntry(info), fn, make(map[string]bool))
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (v *vault) walkEntry(path string, d fs.DirEntry, fn fs.WalkDirFunc, visited map[string]bool) error {
+	if d.Type()&fs.ModeSymlink != 0 {
+		var ok bool
+		if d, ok = v.resolveLink(path, d); !ok {
+			return nil
+		}
+	}
+
+	if d.IsDir() && v.symlinkPolicy() == SymlinkFollow {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			if visited[real] {
+				log.WarnWithFields("⚠️ Skipping folder already reached through a symlink", v.fields(path, nil))
+				return nil
+			}
+			visited[real] = true
+		}
+	}
+
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			return nil
+		}
+		return err
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		// Like filepath.WalkDir, fn sees the folder again with the error
+		if err = fn(path, d, err); err != nil {
+			if err == filepath.SkipDir {
+				return nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := v.walkEntry(filepath.Join(path, entry.Name()), entry, fn, visited); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveLink returns the entry to walk for the symlink at path, if the policy
+// walks it: the link's target when following, the link itself otherwise
+func (v *vault) resolveLink(path string, link fs.DirEntry) (fs.DirEntry, bool) {
+	switch v.symlinkPolicy() {
+	case SymlinkIgnore:
+		log.DebugWithFields("⏭️ Skipping symlink", v.fields(path, nil))
+		return nil, false
+	case SymlinkLink:
+		return link, true
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.DebugWithFields("⏭️ Skipping broken symlink", v.fields(path, nil))
+		return nil, false
+	}
+	return fs.FileInfoToDirEntry(info), true
+}
+
+// isSymlink reports whether path is a symlink, without following it
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode()&fs.ModeSymlink != 0
+}
@@ -1,26 +1,57 @@
 package watcher
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/aarangop/obsidian-sync/internal/logger"
+	"github.com/aarangop/obsidian-sync/internal/metrics"
+	"github.com/aarangop/obsidian-sync/pkg/models"
 	"github.com/fsnotify/fsnotify"
 )
 
+// log is the watcher component logger
+var log = logger.Component("watcher")
+
 // Watcher monitors a directory for file system events.
 // It wraps the fsnotify.Watcher to provide a higher-level interface
 // for watching file system changes in a specified path.
 type Watcher struct {
-	path      string
+	*vault
 	fsWatcher *fsnotify.Watcher
 	done      chan bool
 
+	// lifecycleMu guards fsWatcher, poller and stopped, so Stop is safe while Start is retried
+	lifecycleMu sync.Mutex
+	stopped     bool
+
+	// mu guards the event buffer, which is shared between the watch loop and the debounce timer
+	mu            sync.Mutex
 	eventBuffer   map[string]*fileEvent
 	debounceTimer *time.Timer
+
+	// fallback is the scan interval of the poller taking over when the directories
+	// can't be watched, see SetFallback; poller is that poller once it has
+	fallback time.Duration
+	poller   *Poller
+	// limit returns the kernel's watch limit, see watchLimit
+	limit func() (int, bool)
+
+	// filesMu guards files, the known state of the synced files that rescans compare the disk against
+	filesMu sync.Mutex
+	files   map[string]fileState
+
+	// rescanMu guards the rescan waiting to run, see requestRescan
+	rescanMu       sync.Mutex
+	rescanRoot     string
+	rescanReason   string
+	rescanPending  chan bool
+	rescanInterval time.Duration
 }
 
 type fileEvent struct {
@@ -33,38 +64,82 @@ type fileEvent struct {
 
 func New(path string) *Watcher {
 	return &Watcher{
-		path:        path,
-		done:        make(chan bool), // Create a channel for clean shutdown
-		eventBuffer: make(map[string]*fileEvent),
+		vault:         newVault(path),
+		done:          make(chan bool), // Create a channel for clean shutdown
+		eventBuffer:   make(map[string]*fileEvent),
+		rescanPending: make(chan bool, 1),
+		limit:         watchLimit,
 	}
 }
 
+// SetRescanInterval makes the watcher sweep the whole vault for changes it missed
+// every interval, in addition to rescanning after errors; 0 disables the sweep.
+// Call it before Start.
+func (w *Watcher) SetRescanInterval(interval time.Duration) {
+	w.rescanInterval = interval
+}
+
+// SetFallback makes Start poll the vault every interval instead of failing when
+// its directories can't be watched, e.g. because the kernel's watch limit is
+// reached. Call it before Start.
+func (w *Watcher) SetFallback(interval time.Duration) {
+	w.fallback = interval
+}
+
 // Start initiates the file watching process.
 // It creates a new fsnotify watcher, adds the target directory and all its subdirectories recursively,
 // and launches a goroutine to handle file system events.
 // The method blocks until the watcher's done channel receives a signal.
 //
-// Returns an error if creating the watcher or adding directories fails.
+// Returns an error if the vault path is not an accessible directory, or if creating
+// the watcher or adding directories fails and there is no fallback, see SetFallback.
+// Start can be called again after an error.
+// It returns nil without watching if Stop was already called.
 func (w *Watcher) Start() error {
+	w.lifecycleMu.Lock()
+	if w.stopped {
+		w.lifecycleMu.Unlock()
+		return nil
+	}
+
+	if err := w.checkDir(); err != nil {
+		w.lifecycleMu.Unlock()
+		return err
+	}
+
 	var err error
 	w.fsWatcher, err = fsnotify.NewWatcher()
 
 	if err != nil {
-		logger.Errorf("⚠️ Failed to create file watcher: %v", err)
-		return fmt.Errorf("failed to create file watcher: %v", err)
+		w.fsWatcher = nil
+		return w.poll(fmt.Errorf("failed to create file watcher: %v", err))
 	}
 
 	// Add existing directories recursively
 	err = w.addRecursive(w.path)
 
 	if err != nil {
-		logger.Errorf("⚠️ Failed to add directories: %v", err)
-		return fmt.Errorf("failed to add directories: %v", err)
+		w.fsWatcher.Close()
+		w.fsWatcher = nil
+		return w.poll(fmt.Errorf("failed to add directories: %v", err))
+	}
+
+	// Remember the files as they are, so rescans can tell what changed since
+	w.filesMu.Lock()
+	w.files, err = w.scan(w.path, nil)
+	w.filesMu.Unlock()
+	if err != nil {
+		w.fsWatcher.Close()
+		w.fsWatcher = nil
+		w.lifecycleMu.Unlock()
+		return fmt.Errorf("failed to scan vault: %v", err)
 	}
+	w.lifecycleMu.Unlock()
 	// `go` keyword starts a 'goroutine', a lightweight thread
 	go w.watch()
+	go w.rescanLoop()
 
-	logger.Infof("🔍Watching for %s for changes...", w.path)
+	log.InfoWithFields("🔍 Watching vault for changes", w.fields(w.path, nil))
 
 	// Wait for done signal instead of blocking forever
 	<-w.done
@@ -72,15 +147,41 @@ func (w *Watcher) Start() error {
 	return nil
 }
 
+// poll hands the vault to a poller after watching it failed with err, or returns
+// err without a fallback. It is called with lifecycleMu held and releases it.
+func (w *Watcher) poll(err error) error {
+	if w.fallback <= 0 {
+		w.lifecycleMu.Unlock()
+		log.Errorf("⚠️ %v", err)
+		return err
+	}
+	w.poller = newPoller(w.vault, w.fallback)
+	poller := w.poller
+	w.lifecycleMu.Unlock()
+
+	metrics.WatchedDirectories.WithLabelValues(w.vaultID).Set(0)
+	log.WarnWithFields("⚠️ Cannot watch the vault, polling it instead", w.fields(w.path, logger.Fields{"error": err, "interval": w.fallback}))
+	err = poller.Start()
+
+	// Once the poller is done, Stop and Flush go to the native watcher of a retried Start
+	w.lifecycleMu.Lock()
+	if w.poller == poller {
+		w.poller = nil
+	}
+	w.lifecycleMu.Unlock()
+	return err
+}
+
 // watch starts an infinite monitoring loop for the directory being watched.
 // It processes two types of channel events:
-//  1. File events: Filters for markdown (.md) files and prints the operation and file name.
+//  1. File events: Filters for notes (.md) and canvas boards (.canvas) and prints the operation and file name.
 //     TODO: Will eventually call an HTTP endpoint to process these events.
-//  2. Error events: Logs any errors that occur during watching but continues monitoring.
+//  2. Error events: Logs any errors that occur during watching and rescans the vault
+//     for the changes whose events were lost, but continues monitoring.
 //
 // The function exits when either channel is closed (which happens when the watcher is closed).
 func (w *Watcher) watch() {
-	logger.Infof("File watcher has started watching files in %s", w.path)
+	log.DebugWithFields("👀 File watcher started", w.fields(w.path, nil))
 	// We start an infinite loop
 	for {
 		// `select` statement is like a `switch` but for *channel operations*
@@ -90,22 +191,49 @@ func (w *Watcher) watch() {
 			if !ok {
 				return // Channel closed, exit goroutine
 			}
+			recordRawEvent(event.Op)
 			w.bufferEvent(event)
 		case err, ok := <-w.fsWatcher.Errors:
 			if !ok {
 				return // Channel closed, exit goroutine
 			}
 			// Log error but continue watching
-			logger.Errorf("Error: %v", err)
+			metrics.FsnotifyErrors.Inc()
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				log.WarnWithFields("⚠️ File events were dropped, rescanning the vault", w.fields(w.path, nil))
+				w.requestRescan(w.path, "overflow")
+			} else {
+				log.ErrorWithFields("⚠️ File watcher error", w.fields(w.path, logger.Fields{"error": err}))
+				w.requestRescan(w.path, "error")
+			}
+		}
+	}
+}
+
+// recordRawEvent counts each operation contained in a raw fsnotify event
+func recordRawEvent(op fsnotify.Op) {
+	for _, o := range []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod} {
+		if op.Has(o) {
+			metrics.FsnotifyEvents.WithLabelValues(o.String()).Inc()
 		}
 	}
 }
 
 func (w *Watcher) bufferEvent(event fsnotify.Event) {
-	// Only process markdown files and directories
+	// Only process notes, canvas boards and directories
 	// TODO: Also process images and pdfs, but leave for later
 
-	if !w.isMarkdownFile(event.Name) && !w.isDirectory(event.Name) {
+	if !w.isVaultFile(event.Name) && !w.isDirectory(event.Name) {
+		// A folder moved or deleted with its notes only reports itself
+		if event.Op.Has(fsnotify.Remove) || event.Op.Has(fsnotify.Rename) {
+			if w.knowsFilesIn(event.Name) {
+				w.requestRescan(event.Name, "directory")
+			}
+		}
+		return
+	}
+
+	if w.isIgnored(event.Name) {
 		return
 	}
 
@@ -115,6 +243,9 @@ func (w *Watcher) bufferEvent(event fsnotify.Event) {
 	}
 
 	// Buffer file events for debouncing
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	now := time.Now()
 
 	// Get or create file event record
@@ -127,23 +258,21 @@ func (w *Watcher) bufferEvent(event fsnotify.Event) {
 
 	fe.lastSeen = now
 
+	log.DebugWithFields("🔍 File system event", w.fields(event.Name, logger.Fields{"op": event.Op.String()}))
+
 	if event.Op&fsnotify.Create == fsnotify.Create {
-		logger.Debugf("✅ CREATE event for %s, %s", event.Name, event.Op.String())
 		fe.isNew = true
 	}
 
 	if event.Op&fsnotify.Write == fsnotify.Write {
-		logger.Debugf("🔍 WRITE event for %s, %s", event.Name, event.Op.String())
 		fe.isModified = true
 	}
 
 	if event.Op&fsnotify.Remove == fsnotify.Remove {
-		logger.Debugf("🔍 REMOVE event for %s, %s", event.Name, event.Op.String())
 		fe.isDeleted = true
 	}
 
 	if event.Op&fsnotify.Rename == fsnotify.Rename {
-		logger.Debugf("🔍 RENAME event for %s, %s", event.Name, event.Op.String())
 		fe.isDeleted = true // Treat rename as deletion of old name
 	}
 
@@ -151,142 +280,300 @@ func (w *Watcher) bufferEvent(event fsnotify.Event) {
 		w.debounceTimer.Stop()
 	}
 
-	// Process events after 100ms of quiet
-	w.debounceTimer = time.AfterFunc(100*time.Millisecond, w.processBufferedEvents)
+	// Process events after the debounce interval of quiet
+	w.settingsMu.RLock()
+	debounce := w.debounce
+	w.settingsMu.RUnlock()
+	w.debounceTimer = time.AfterFunc(debounce, w.processBufferedEvents)
 }
 
 func (w *Watcher) handleDirectoryEvent(event fsnotify.Event) {
-	if event.Op&fsnotify.Create == fsnotify.Create {
-		logger.Infof("📁 New directory created: %s", event.Name)
-		if err := w.fsWatcher.Add(event.Name); err != nil {
-			logger.Warnf("⚠️ Failed to watch new directory: %s: %v", event.Name, err)
+	if event.Op&fsnotify.Create == fsnotify.Create && w.isWatchedDirectory(event.Name) {
+		log.InfoWithFields("📁 New directory created", w.fields(event.Name, nil))
+		if err := w.addRecursive(event.Name); err != nil {
+			log.ErrorWithFields("⚠️ Failed to watch new directory", w.fields(event.Name, logger.Fields{"error": err}))
 		}
+		// Its files were created or moved in before it was watched
+		w.requestRescan(event.Name, "directory")
 	}
 }
 
 func (w *Watcher) processBufferedEvents() {
-	now := time.Now()
+	w.mu.Lock()
+	var events []models.FileEvent
 
 	for path, fe := range w.eventBuffer {
-		// Skip events that are too old
-		if now.Sub(fe.lastSeen) > 5*time.Second {
-			delete(w.eventBuffer, path)
-			continue
-		}
-
 		// Determine the primary action
 		if fe.isDeleted {
-			logger.Infof("🗑️  File deleted: %s", path)
-			// TODO: Send delete event to queue
+			log.InfoWithFields("🗑️  File deleted", w.fields(path, nil))
+			events = append(events, w.newEvent(path, models.EventDeleted))
 
 		} else if fe.isNew && !fe.isModified {
 			// File was created but not written to (rare)
-			logger.Infof("✅ File created (empty): %s", path)
-			// TODO: Send create event to queue
+			log.InfoWithFields("✅ File created (empty)", w.fields(path, nil))
+			events = append(events, w.newEvent(path, models.EventCreated))
 
 		} else if fe.isNew && fe.isModified {
 			// File was created and has content (most "new file" cases)
-			logger.Infof("✅ File created: %s", path)
-			// TODO: Send create event to queue
+			log.InfoWithFields("✅ File created", w.fields(path, nil))
+			events = append(events, w.newEvent(path, models.EventCreated))
 
 		} else if fe.isModified {
 			// File was modified (existing file edited)
-			logger.Infof("✏️  File modified: %s", path)
-			// TODO: Send modify event to queue
+			log.InfoWithFields("✏️  File modified", w.fields(path, nil))
+			events = append(events, w.newEvent(path, models.EventModified))
 
 		} else {
-			logger.Warnf("🤷 Unknown event pattern for: %s", path)
+			log.WarnWithFields("🤷 Unknown event pattern", w.fields(path, nil))
 		}
 
 		delete(w.eventBuffer, path)
 	}
-}
+	w.mu.Unlock()
+	w.remember(events)
 
-func (w *Watcher) Stop() error {
-	// Check if fsWatcher is initialized
-	if w.fsWatcher != nil {
-		close(w.done)
-		return w.fsWatcher.Close()
+	// Dispatch outside the lock so slow handlers don't stall the watch loop
+	for _, event := range events {
+		w.emit(event)
 	}
+}
 
-	return nil
+// remember records the state of the files of emitted events, so rescans only
+// report the changes that had no event
+func (w *Watcher) remember(events []models.FileEvent) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	if w.files == nil {
+		return
+	}
+	for _, event := range events {
+		if state, exists := w.stat(event.FilePath); exists && event.EventType != models.EventDeleted {
+			w.files[event.FilePath] = state
+		} else {
+			delete(w.files, event.FilePath)
+		}
+	}
 }
 
-func (w *Watcher) handleEvent(event fsnotify.Event) {
-	// Get file info
-	info, err := os.Stat(event.Name)
+// knowsFilesIn reports whether any known file is in the folder at path
+func (w *Watcher) knowsFilesIn(path string) bool {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+	for file := range w.files {
+		if within(file, path) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Handle different event types
+// requestRescan asks the rescan loop to look for changes under root, a folder of
+// the vault. Requests waiting together are merged, widening to the whole vault.
+func (w *Watcher) requestRescan(root, reason string) {
+	w.rescanMu.Lock()
 	switch {
-	case event.Op&fsnotify.Create == fsnotify.Create:
-		if err == nil && info.IsDir() {
-			logger.Infof("📁 New directory created: %s", event.Name)
-			if err := w.fsWatcher.Add(event.Name); err != nil {
-				logger.Warnf("⚠️ Failed to watch new directory %s: %v", event.Name, err)
-			}
-		} else if w.isMarkdownFile(event.Name) {
-			logger.Infof("✅ File created: %s", event.Name)
-		}
-	case event.Op&fsnotify.Write == fsnotify.Write:
-		if w.isMarkdownFile(event.Name) {
-			logger.Infof("✏️ File modified: %s", event.Name)
+	case w.rescanRoot == "" || within(w.rescanRoot, root):
+		w.rescanRoot, w.rescanReason = root, reason
+	case !within(root, w.rescanRoot):
+		w.rescanRoot, w.rescanReason = w.path, reason
+	}
+	w.rescanMu.Unlock()
+
+	select {
+	case w.rescanPending <- true:
+	default:
+	}
+}
+
+// rescanLoop runs the requested rescans one at a time, and sweeps the whole vault
+// every rescan interval for changes missed without an error
+func (w *Watcher) rescanLoop() {
+	var sweep <-chan time.Time
+	if w.rescanInterval > 0 {
+		ticker := time.NewTicker(w.rescanInterval)
+		defer ticker.Stop()
+		sweep = ticker.C
+	}
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.rescanPending:
+			w.rescanMu.Lock()
+			root, reason := w.rescanRoot, w.rescanReason
+			w.rescanRoot = ""
+			w.rescanMu.Unlock()
+			w.rescan(root, reason)
+		case <-sweep:
+			w.rescan(w.path, "sweep")
 		}
 	}
 }
 
-func (w *Watcher) isMarkdownFile(filename string) bool {
-	if filepath.Ext(filename) != ".md" {
-		return false
+// rescan compares the files under root with their known state and emits events
+// for the changes the watcher missed. A sweep leaves files still being written
+// to their pending events; after lost events every change is reported at once.
+func (w *Watcher) rescan(root, reason string) {
+	metrics.Rescans.WithLabelValues(reason).Inc()
+
+	// Deliver the buffered events first, so they aren't reported twice
+	w.Flush()
+
+	w.filesMu.Lock()
+	scanned, err := w.scan(root, w.files)
+	if err != nil {
+		w.filesMu.Unlock()
+		log.WarnWithFields("⚠️ Failed to rescan", w.fields(root, logger.Fields{"reason": reason, "error": err}))
+		return
 	}
+	events := w.compare(w.files, scanned, root, reason != "sweep")
+	w.filesMu.Unlock()
 
-	base := filepath.Base(filename)
-	if strings.HasPrefix(base, ".") || strings.HasPrefix(base, "~") {
-		return false
+	if len(events) > 0 {
+		log.WarnWithFields("🔄 Rescan found missed changes", w.fields(root, logger.Fields{"reason": reason, "count": len(events)}))
+	} else {
+		log.DebugWithFields("🔄 Rescan found no missed changes", w.fields(root, logger.Fields{"reason": reason}))
 	}
+	for _, event := range events {
+		w.emit(event)
+	}
+}
 
-	return true
+// Flush emits all buffered events immediately instead of waiting for the debounce timer.
+func (w *Watcher) Flush() {
+	w.lifecycleMu.Lock()
+	poller := w.poller
+	w.lifecycleMu.Unlock()
+	if poller != nil {
+		poller.Flush()
+		return
+	}
+
+	w.mu.Lock()
+	if w.debounceTimer != nil {
+		w.debounceTimer.Stop()
+	}
+	w.mu.Unlock()
+
+	w.processBufferedEvents()
 }
 
-func (w *Watcher) isDirectory(filename string) bool {
-	info, err := os.Stat(filename)
-	return err == nil && info.IsDir()
+func (w *Watcher) Stop() error {
+	w.lifecycleMu.Lock()
+	if w.stopped {
+		w.lifecycleMu.Unlock()
+		return nil
+	}
+	w.stopped = true
+	fsWatcher, poller := w.fsWatcher, w.poller
+	w.lifecycleMu.Unlock()
+
+	if poller != nil {
+		return poller.Stop()
+	}
+
+	// Check if fsWatcher is initialized
+	if fsWatcher != nil {
+		w.Flush()
+		close(w.done)
+		return fsWatcher.Close()
+	}
+
+	return nil
 }
 
-// Adds a directory and all its subdirectories to the watcher
-// addRecursive adds watches recursively to the given root directory and all its subdirectories.
-// It returns an error if the root directory cannot be accessed or if there's an issue adding
-// watches to any of the directories in the hierarchy.
-// addRecursive recursively adds all directories and subdirectories starting from the given root path to the file system watcher.
-// It skips any system directories (those prefixed with a dot '.') except for the root directory itself.
-// For each path, it logs success or failure of adding the path to the watcher.
-//
-// Parameters:
-//   - root: The starting directory path to begin recursive watching
-//
-// Returns:
-//   - error: Any error that occurs during directory traversal
+// addRecursive watches root and the directories below it, skipping hidden and
+// ignored ones. Only directories are watched: a directory's watch reports the
+// changes to the files in it. Before adding the watches the directories already
+// watched and the new ones are checked against the kernel's watch limit, which
+// the whole vault must fit in.
 func (w *Watcher) addRecursive(root string) error {
-	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	var dirs []string
+	err := w.walk(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
-			logger.Warnf("⚠️ Error accessing %s: %v", path, err)
+			log.WarnWithFields("⚠️ Error accessing path", w.fields(path, logger.Fields{"error": err}))
 			return nil
 		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != root && !w.isWatchedDirectory(path) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// Skip system directories, prefixed with '.'
-		if d.IsDir() {
-			if strings.HasPrefix(d.Name(), ".") && path != root {
-				return filepath.SkipDir
+	if limit, ok := w.limit(); ok {
+		watched := make(map[string]bool)
+		for _, dir := range w.fsWatcher.WatchList() {
+			watched[dir] = true
+		}
+		count := len(watched)
+		for _, dir := range dirs {
+			if !watched[dir] {
+				count++
 			}
 		}
+		if err := checkWatchLimit(count, limit); err != nil {
+			return err
+		}
+	}
 
-		err = w.fsWatcher.Add(path)
-
-		if err != nil {
-			logger.Warnf("⚠️ Failed to watch directory %s: %v", path, err)
-		} else {
-			logger.Debugf("📁 Added directory to watch: %s", path)
+	defer w.updateWatchCount()
+	for _, dir := range dirs {
+		if err := w.addWatch(dir); err != nil {
+			return err
 		}
+	}
 
+	log.InfoWithFields("📁 Watching directories", w.fields(root, logger.Fields{"count": len(dirs)}))
+	return nil
+}
+
+// addWatch watches a directory. Failing to watch one directory is only logged,
+// unless the kernel's watch limit is reached, which no other directory would fit in either.
+func (w *Watcher) addWatch(dir string) error {
+	err := w.fsWatcher.Add(dir)
+
+	if errors.Is(err, syscall.ENOSPC) {
+		limit, _ := w.limit()
+		return fmt.Errorf("cannot watch %s: reached the kernel's limit of %d watched directories, raise it with: sudo sysctl fs.inotify.max_user_watches=%d", dir, limit, suggestedLimit(limit))
+	}
+	if err != nil {
+		log.WarnWithFields("⚠️ Failed to watch directory", w.fields(dir, logger.Fields{"error": err}))
 		return nil
-	})
+	}
+	log.DebugWithFields("📁 Added directory to watch", w.fields(dir, nil))
+	return nil
+}
+
+// updateWatchCount reports the number of watched directories
+func (w *Watcher) updateWatchCount() {
+	metrics.WatchedDirectories.WithLabelValues(w.vaultID).Set(float64(len(w.fsWatcher.WatchList())))
+}
+
+// watchLimitWarning is the share of the kernel's watch limit a vault may use without a warning
+const watchLimitWarning = 0.8
+
+// checkWatchLimit fails when count directories can't be watched within the kernel's
+// limit, and warns when they would use most of it. The limit is shared with
+// other vaults and programs, so reaching it can also break them.
+func checkWatchLimit(count, limit int) error {
+	if count >= limit {
+		return fmt.Errorf("vault has %d directories but fs.inotify.max_user_watches is %d, raise it with: sudo sysctl fs.inotify.max_user_watches=%d", count, limit, suggestedLimit(count))
+	}
+	if float64(count) >= watchLimitWarning*float64(limit) {
+		log.Warnf("⚠️ Watching %d directories uses most of fs.inotify.max_user_watches (%d), consider raising it with: sudo sysctl fs.inotify.max_user_watches=%d", count, limit, suggestedLimit(count))
+	}
+	return nil
+}
+
+// suggestedLimit is a watch limit leaving room for count directories to double
+func suggestedLimit(count int) int {
+	return max(2*count, 524288)
 }
@@ -3,8 +3,11 @@ package watcher
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/aarangop/obsidian-sync/pkg/models"
 )
 
 func TestNew(t *testing.T) {
@@ -45,3 +48,478 @@ func TestWatcherWithTempDir(t *testing.T) {
 	// In a real test, you'd verify the watcher detected the change
 	// For now, this just ensures no crashes
 }
+
+func TestWatcherEmitsEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	w := New(tmpDir)
+	events := make(chan models.FileEvent, 10)
+	w.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	testFile := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(testFile, []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.EventType != models.EventCreated {
+			t.Errorf("Expected event type %s, got %s", models.EventCreated, event.EventType)
+		}
+		if event.FilePath != testFile {
+			t.Errorf("Expected file path %s, got %s", testFile, event.FilePath)
+		}
+		if event.FileSize != 6 {
+			t.Errorf("Expected file size 6, got %d", event.FileSize)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for file event")
+	}
+}
+
+func TestWatcherKeepsEventsWithLongDebounce(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	w := New(tmpDir)
+	w.SetDebounce(6 * time.Second)
+	events := make(chan models.FileEvent, 10)
+	w.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	testFile := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(testFile, []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Age the buffered change as if the debounce interval had passed
+	w.mu.Lock()
+	for _, fe := range w.eventBuffer {
+		fe.lastSeen = fe.lastSeen.Add(-6 * time.Second)
+	}
+	w.mu.Unlock()
+	w.Flush()
+
+	select {
+	case event := <-events:
+		if event.FilePath != testFile {
+			t.Errorf("Expected file path %s, got %s", testFile, event.FilePath)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the change to be emitted after a debounce over 5s")
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	w := New("/vault")
+	w.SetIgnorePatterns([]string{"templates", "*.excalidraw.md", "archive/2023"})
+
+	tests := []struct {
+		path    string
+		ignored bool
+	}{
+		{"/vault/templates", true},
+		{"/vault/templates/daily.md", true},
+		{"/vault/notes/templates/x.md", true},
+		{"/vault/drawing.excalidraw.md", true},
+		{"/vault/archive/2023/old.md", true},
+		{"/vault/archive/2024/new.md", false},
+		{"/vault/notes/idea.md", false},
+	}
+
+	for _, tt := range tests {
+		if got := w.isIgnored(tt.path); got != tt.ignored {
+			t.Errorf("isIgnored(%s) = %t, expected %t", tt.path, got, tt.ignored)
+		}
+	}
+}
+
+func TestWatcherParsesCanvas(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	w := New(tmpDir)
+	events := make(chan models.FileEvent, 10)
+	w.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	board := `{"nodes": [{"id": "a1", "type": "file", "file": "note.md"}], "edges": []}`
+	testFile := filepath.Join(tmpDir, "Roadmap.canvas")
+	if err := os.WriteFile(testFile, []byte(board), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-events:
+		if event.FilePath != testFile {
+			t.Errorf("Expected file path %s, got %s", testFile, event.FilePath)
+		}
+		if event.Canvas == nil || len(event.Canvas.Files) != 1 || event.Canvas.Files[0].File != "note.md" {
+			t.Errorf("Expected the parsed canvas, got %+v", event.Canvas)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for canvas event")
+	}
+}
+
+func TestWatcherKnowsObsidianLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, ".obsidian"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(note, []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(tmpDir)
+	w.SetDebounce(20 * time.Millisecond)
+	w.SetSyncSettings(true)
+	events := make(chan models.FileEvent, 20)
+	w.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The trash appears with the first deleted note; moving into it is a deletion
+	trash := filepath.Join(tmpDir, ".trash")
+	if err := os.Mkdir(trash, 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := os.Rename(note, filepath.Join(trash, "note.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	writes := map[string]string{
+		".DS_Store": "junk",
+		"note.sync-conflict-20250608-143000-ABCDEFG.md": "# Conflict",
+		".obsidian/workspace.json":                      `{"main": {}}`,
+		".obsidian/app.json":                            `{"vimMode": true}`,
+	}
+	for name, content := range writes {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]models.EventType)
+	timeout := time.After(500 * time.Millisecond)
+collect:
+	for {
+		select {
+		case event := <-events:
+			rel, _ := filepath.Rel(tmpDir, event.FilePath)
+			got[filepath.ToSlash(rel)] = event.EventType
+		case <-timeout:
+			break collect
+		}
+	}
+
+	if got["note.md"] != models.EventDeleted {
+		t.Errorf("Expected the trashed note to be deleted, got %v", got)
+	}
+	if _, exists := got[".obsidian/app.json"]; !exists {
+		t.Errorf("Expected an event for the app settings, got %v", got)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected only the deletion and the settings change, got %v", got)
+	}
+}
+
+func TestWatcherOnlyWatchesDirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"daily", "projects/2025", ".git/objects"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"note.md", "daily/2025-06-08.md", "projects/photo.png", ".DS_Store"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, filepath.FromSlash(file)), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := New(tmpDir)
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// The vault, daily, projects and projects/2025
+	w.lifecycleMu.Lock()
+	watched := w.fsWatcher.WatchList()
+	w.lifecycleMu.Unlock()
+	if len(watched) != 4 {
+		t.Errorf("Expected 4 watched directories, got %v", watched)
+	}
+}
+
+func TestCheckWatchLimit(t *testing.T) {
+	if err := checkWatchLimit(100, 8192); err != nil {
+		t.Errorf("Expected no error well within the limit, got %v", err)
+	}
+	err := checkWatchLimit(9000, 8192)
+	if err == nil || !strings.Contains(err.Error(), "sysctl fs.inotify.max_user_watches=524288") {
+		t.Errorf("Expected an error suggesting a higher limit, got %v", err)
+	}
+}
+
+func TestWatcherChecksNewDirectoriesAgainstWatchedOnes(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmpDir, "daily"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The vault and daily fit, a third directory reaches the limit
+	w := New(tmpDir)
+	w.limit = func() (int, bool) { return 3, true }
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.Mkdir(filepath.Join(tmpDir, "projects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	w.lifecycleMu.Lock()
+	watched := w.fsWatcher.WatchList()
+	w.lifecycleMu.Unlock()
+	if len(watched) != 2 {
+		t.Errorf("Expected the new directory not to be watched past the limit, got %v", watched)
+	}
+}
+
+func TestWatcherStopsAfterFailedPollingAndNativeRetry(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Watching fails on the limit and the poller finds the vault gone, as on a flapping mount
+	w := New(tmpDir)
+	w.SetFallback(20 * time.Millisecond)
+	w.limit = func() (int, bool) {
+		os.RemoveAll(tmpDir)
+		return 1, true
+	}
+	if err := w.Start(); err == nil {
+		t.Fatal("Expected the poller to fail without the vault")
+	}
+
+	if err := os.Mkdir(tmpDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w.limit = func() (int, bool) { return 0, false }
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- w.Start()
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := w.Stop(); err != nil {
+		t.Errorf("Expected no error stopping, got %v", err)
+	}
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Expected the native watch to end without error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Stop to end the native watch")
+	}
+}
+
+func TestWatcherRescansMovedFolders(t *testing.T) {
+	tmpDir := t.TempDir()
+	vaultPath := filepath.Join(tmpDir, "vault")
+	outside := filepath.Join(tmpDir, "outside")
+	for _, dir := range []string{filepath.Join(vaultPath, "archive"), filepath.Join(outside, "projects")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archived := filepath.Join(vaultPath, "archive", "old.md")
+	if err := os.WriteFile(archived, []byte("# Old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "projects", "plan.md"), []byte("# Plan"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(vaultPath)
+	events := make(chan models.FileEvent, 10)
+	w.OnEvent(func(event models.FileEvent) {
+		events <- event
+	})
+	go func() {
+		if err := w.Start(); err != nil {
+			t.Errorf("Failed to start watcher: %v", err)
+		}
+	}()
+	defer w.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	// Moving a folder only reports the folder, not the notes in it
+	if err := os.Rename(filepath.Join(outside, "projects"), filepath.Join(vaultPath, "projects")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(vaultPath, "archive"), filepath.Join(outside, "archive")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]models.EventType{
+		filepath.Join(vaultPath, "projects", "plan.md"): models.EventCreated,
+		archived: models.EventDeleted,
+	}
+	for len(expected) > 0 {
+		select {
+		case event := <-events:
+			want, ok := expected[event.FilePath]
+			if !ok || event.EventType != want {
+				t.Errorf("Unexpected %s event for %s", event.EventType, event.FilePath)
+			}
+			delete(expected, event.FilePath)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for events, missing %v", expected)
+		}
+	}
+}
+
+func TestRescanReportsMissedChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	note := filepath.Join(tmpDir, "note.md")
+	if err := os.WriteFile(note, []byte("# Note"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(tmpDir)
+	var events []models.FileEvent
+	w.OnEvent(func(event models.FileEvent) {
+		events = append(events, event)
+	})
+	var err error
+	if w.files, err = w.scan(tmpDir, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	w.rescan(tmpDir, "sweep")
+	if len(events) != 0 {
+		t.Errorf("Expected no events for an unchanged vault, got %v", events)
+	}
+
+	// Changes made while events were dropped
+	missed := filepath.Join(tmpDir, "missed.md")
+	if err := os.WriteFile(missed, []byte("# Missed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(note); err != nil {
+		t.Fatal(err)
+	}
+
+	w.rescan(tmpDir, "overflow")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %v", events)
+	}
+	if events[0].FilePath != missed || events[0].EventType != models.EventCreated {
+		t.Errorf("Expected created event for %s, got %s for %s", missed, events[0].EventType, events[0].FilePath)
+	}
+	if events[1].FilePath != note || events[1].EventType != models.EventDeleted {
+		t.Errorf("Expected deleted event for %s, got %s for %s", note, events[1].EventType, events[1].FilePath)
+	}
+}
+
+func TestSymlinkPolicies(t *testing.T) {
+	tmpDir := t.TempDir()
+	vaultPath := filepath.Join(tmpDir, "vault")
+	shared := filepath.Join(tmpDir, "shared")
+	for _, dir := range []string{vaultPath, shared} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{filepath.Join(vaultPath, "note.md"), filepath.Join(shared, "reference.md")} {
+		if err := os.WriteFile(file, []byte("# Note"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A shared folder linked in, and a link back to the vault that would loop
+	if err := os.Symlink(shared, filepath.Join(vaultPath, "shared")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(vaultPath, filepath.Join(vaultPath, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy   string
+		expected map[string]string
+	}{
+		{SymlinkIgnore, map[string]string{"note.md": ""}},
+		{SymlinkFollow, map[string]string{"note.md": "", filepath.Join("shared", "reference.md"): ""}},
+		{SymlinkLink, map[string]string{"note.md": "", "shared": shared, "loop": vaultPath}},
+	}
+	for _, tt := range tests {
+		w := New(vaultPath)
+		w.SetSymlinks(tt.policy)
+		synced := make(map[string]string)
+		w.OnEvent(func(event models.FileEvent) {
+			rel, _ := filepath.Rel(vaultPath, event.FilePath)
+			synced[rel] = event.LinkTarget
+		})
+
+		if _, err := w.Resync(""); err != nil {
+			t.Fatalf("%s: expected no error, got %v", tt.policy, err)
+		}
+		if len(synced) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.policy, tt.expected, synced)
+		}
+		for path, target := range tt.expected {
+			if got, ok := synced[path]; !ok || got != target {
+				t.Errorf("%s: expected %s to be synced with link target %q, got %q", tt.policy, path, target, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+// Canvas is the content of an Obsidian canvas board, attached to the events of .canvas files.
+type Canvas struct {
+	Texts []CanvasText `json:"texts"`
+	Files []CanvasFile `json:"files"`
+	Links []CanvasLink `json:"links"`
+	Edges []CanvasEdge `json:"edges"`
+}
+
+// CanvasText is a text card, written in markdown.
+type CanvasText struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// CanvasFile is a card showing a file of the vault, a note or an attachment.
+type CanvasFile struct {
+	ID   string `json:"id"`
+	File string `json:"file"`
+	// Subpath points at a heading or block within the file, e.g. "#Goals"
+	Subpath string `json:"subpath,omitempty"`
+}
+
+// CanvasLink is a card showing a web page.
+type CanvasLink struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// CanvasEdge connects two cards.
+type CanvasEdge struct {
+	ID    string `json:"id"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+}
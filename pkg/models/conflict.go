@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Conflict describes a file that changed both locally and remotely since it was
+// last synced, and how it was resolved.
+type Conflict struct {
+	Path    string `json:"path"`
+	VaultID string `json:"vault_id,omitempty"`
+	// Policy is the configured conflict policy, Resolution the one applied,
+	// e.g. keep-both when a merge wasn't possible
+	Policy     string `json:"policy"`
+	Resolution string `json:"resolution"`
+	// Copy is the path the local version was kept under with keep-both
+	Copy string    `json:"copy,omitempty"`
+	Time time.Time `json:"time"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// EventType describes what happened to a file in the vault.
+type EventType string
+
+const (
+	EventCreated  EventType = "file_created"
+	EventModified EventType = "file_modified"
+	EventDeleted  EventType = "file_deleted"
+)
+
+// FileEvent is the JSON payload produced for every debounced change in the vault.
+type FileEvent struct {
+	EventType EventType `json:"event_type"`
+	FilePath  string    `json:"file_path"`
+	VaultPath string    `json:"vault_path"`
+	Timestamp time.Time `json:"timestamp"`
+	FileSize  int64     `json:"file_size"`
+	Checksum  string    `json:"checksum"`
+
+	// Canvas is the parsed board of a .canvas file, nil for other files
+	Canvas *Canvas `json:"canvas,omitempty"`
+	// LinkTarget is where a symlink synced as a link points; its size and
+	// checksum are those of the target path
+	LinkTarget string `json:"link_target,omitempty"`
+
+	// VaultID identifies the vault the file belongs to when the daemon syncs several
+	VaultID string `json:"vault_id,omitempty"`
+
+	// Sequence is assigned when the event is published to live subscribers
+	Sequence uint64 `json:"sequence,omitempty"`
+}